@@ -103,6 +103,11 @@ func (node *BaseEventNode) AttachBus(bus *eventd.EventBus[ogcore.State]) {
 	node.EventBus = bus
 }
 
+// EmitPayload emits event with payload alongside state, it does nothing if bus is not attached.
+func (node *BaseEventNode) EmitPayload(event string, state ogcore.State, payload any) {
+	EmitPayload(node.EventBus, event, state, payload)
+}
+
 type BaseState struct {
 	store map[any]any
 
@@ -127,6 +132,16 @@ func (state *BaseState) Set(key any, val any) {
 	state.store[key] = val
 }
 
+// SetBatch sets all vals at once, other goroutines see either none or all of them.
+func (state *BaseState) SetBatch(vals map[any]any) {
+	state.Lock()
+	defer state.Unlock()
+
+	for key, val := range vals {
+		state.store[key] = val
+	}
+}
+
 func (state *BaseState) Update(key any, updateFunc func(val any) any) {
 	state.Lock()
 	defer state.Unlock()
@@ -2,6 +2,7 @@ package ograph
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -13,6 +14,8 @@ import (
 
 type Builder struct {
 	Factories *ogcore.Factories
+
+	mu sync.Mutex
 }
 
 func (builder *Builder) RegisterPrototype(name string, prototype ogcore.Cloneable) *Builder {
@@ -25,18 +28,25 @@ func (builder *Builder) RegisterPrototype(name string, prototype ogcore.Cloneabl
 }
 
 func (builder *Builder) RegisterFactory(name string, factory func() ogcore.Node) *Builder {
+	builder.factories().Add(name, factory)
+	return builder
+}
+
+// factories returns Factories, global factories are copied when it's used first time,
+// which can happen in concurrent runs.
+func (builder *Builder) factories() *ogcore.Factories {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+
 	if builder.Factories == nil {
 		builder.Factories = global.Factories.Clone()
 	}
 
-	builder.Factories.Add(name, factory)
-	return builder
+	return builder.Factories
 }
 
 func (builder *Builder) build(graph *PGraph, eventBus *eventd.EventBus[ogcore.State]) (*internal.Worker, error) {
-	if builder.Factories == nil {
-		builder.Factories = global.Factories.Clone()
-	}
+	builder.factories()
 
 	txManager := internal.NewTransactionManager()
 
@@ -108,15 +118,12 @@ func (builder *Builder) doBuild(element *Element, txManager *internal.Transactio
 		eventNode.AttachBus(eventBus)
 	}
 
-	if txNode, ok := node.(ogcore.Transactional); ok {
-		node = txManager.Manage(txNode)
+	if internal.IsTransactional(node) {
+		node = txManager.Manage(node)
 	}
 
 	if pipeline, ok := node.(*Pipeline); ok {
-		pipeline.Subscribe(func(event string, obj ogcore.State) bool {
-			eventBus.Emit(event, obj)
-			return true
-		}, eventd.On(".*"))
+		pipeline.forwardTo(eventBus)
 	}
 
 	seenWrapper := make(map[string]bool)
@@ -154,6 +161,10 @@ func (builder *Builder) doBuild(element *Element, txManager *internal.Transactio
 				return nil, fmt.Errorf("can't init wrapper %s, err: %v", element.Name, err)
 			}
 
+			if eventNode, ok := wrapperNode.(ogcore.EventNode); ok {
+				eventNode.AttachBus(eventBus)
+			}
+
 			if wrapper, ok := wrapperNode.(ogcore.Wrapper); ok {
 				wrapper.Wrap(node)
 				node = wrapperNode
@@ -0,0 +1,377 @@
+package ograph
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/symphony09/ograph/internal"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var ErrNotPaused error = errors.New("debugger is not paused")
+var ErrDebuggerRunning error = errors.New("debugger is already running")
+
+const (
+	VertexTodo    = internal.VertexTodo
+	VertexReady   = internal.VertexReady
+	VertexRunning = internal.VertexRunning
+	VertexDone    = internal.VertexDone
+)
+
+// DebugPause describes where a debugging run is paused, the node is about to start.
+type DebugPause struct {
+	Node     string
+	Vertices map[string]string
+
+	// CondErr is set if the condition of breakpoint can't be evaluated.
+	CondErr error
+}
+
+// Debugger runs pipeline step by step, it pauses the scheduling before nodes start,
+// nodes which are already running are not paused.
+type Debugger struct {
+	pipeline *Pipeline
+
+	breakpoints map[string]breakpoint
+	stepping    bool
+	running     bool
+
+	ctx    context.Context
+	state  ogcore.State
+	paused *DebugPause
+	pauses chan *DebugPause
+	resume chan struct{}
+
+	sync.Mutex
+}
+
+type breakpoint struct {
+	CondExpr string
+	Cond     func(state ogcore.State) (bool, error)
+}
+
+// SetBreakpoint pauses the run before node starts, "*" matches every node.
+// If condExpr is not empty, it's evaluated with state and the run pauses only if it's true.
+func (dbg *Debugger) SetBreakpoint(node string, condExpr string) error {
+	bp := breakpoint{CondExpr: condExpr}
+
+	if condExpr != "" {
+		stateExpr, err := internal.CompileStateExpr(condExpr)
+		if err != nil {
+			return err
+		}
+
+		bp.Cond = func(state ogcore.State) (bool, error) {
+			return stateExpr.RunBool(state, nil)
+		}
+	}
+
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	dbg.breakpoints[node] = bp
+
+	return nil
+}
+
+func (dbg *Debugger) ClearBreakpoint(node string) {
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	delete(dbg.breakpoints, node)
+}
+
+// Breakpoints returns breakpoints with their condition expression.
+func (dbg *Debugger) Breakpoints() map[string]string {
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	ret := make(map[string]string, len(dbg.breakpoints))
+
+	for node, bp := range dbg.breakpoints {
+		ret[node] = bp.CondExpr
+	}
+
+	return ret
+}
+
+// Start runs pipeline in background, pauses are sent to the channel returned by Pauses.
+func (dbg *Debugger) Start(ctx context.Context, state ogcore.State) (wait func() error) {
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	if dbg.running {
+		return func() error {
+			return ErrDebuggerRunning
+		}
+	}
+
+	dbg.pauses = make(chan *DebugPause)
+
+	newCtx, newState, worker, params, afterRun, err := dbg.pipeline.prepare(ctx, state)
+	if err != nil {
+		close(dbg.pauses)
+
+		return func() error {
+			return err
+		}
+	}
+
+	dbg.running = true
+	dbg.ctx, dbg.state = newCtx, newState
+	params.Hook = dbg.hook
+
+	pauses := dbg.pauses
+	errCh := make(chan error, 1)
+
+	go func() {
+		err := worker.Work(newCtx, newState, params)
+
+		afterRun()
+
+		// run is marked finished before its result is sent, so that it can be restarted once wait returns.
+		dbg.Lock()
+		dbg.running = false
+		close(pauses)
+		dbg.Unlock()
+
+		errCh <- err
+	}()
+
+	return func() error {
+		return <-errCh
+	}
+}
+
+// Pauses returns channel of pauses of current run, it's closed when the run finished.
+func (dbg *Debugger) Pauses() <-chan *DebugPause {
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	return dbg.pauses
+}
+
+// Step resumes the run and pauses before the next node starts.
+func (dbg *Debugger) Step() error {
+	return dbg.doResume(true)
+}
+
+// Continue resumes the run until next breakpoint.
+func (dbg *Debugger) Continue() error {
+	return dbg.doResume(false)
+}
+
+func (dbg *Debugger) doResume(stepping bool) error {
+	dbg.Lock()
+
+	if dbg.paused == nil {
+		dbg.Unlock()
+		return ErrNotPaused
+	}
+
+	dbg.stepping = stepping
+	dbg.paused = nil
+	ctx := dbg.ctx
+
+	dbg.Unlock()
+
+	select {
+	case dbg.resume <- struct{}{}:
+	case <-ctx.Done():
+	}
+
+	return nil
+}
+
+// State returns state of current run, it can be inspected and edited while paused.
+func (dbg *Debugger) State() ogcore.State {
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	return dbg.state
+}
+
+// Vertices returns status of vertices when paused, the status is one of todo, ready, running and done.
+func (dbg *Debugger) Vertices() (map[string]string, error) {
+	dbg.Lock()
+	defer dbg.Unlock()
+
+	if dbg.paused == nil {
+		return nil, ErrNotPaused
+	}
+
+	return maps.Clone(dbg.paused.Vertices), nil
+}
+
+func (dbg *Debugger) hook(name string, event string, snapshot func() map[string]string) {
+	if event != "start" {
+		return
+	}
+
+	dbg.Lock()
+
+	pause := &DebugPause{Node: name}
+
+	if !dbg.stepping {
+		bp, ok := dbg.breakpoints[name]
+		if !ok {
+			bp, ok = dbg.breakpoints["*"]
+		}
+
+		if !ok {
+			dbg.Unlock()
+			return
+		}
+
+		if bp.Cond != nil {
+			if hit, err := bp.Cond(dbg.state); err != nil {
+				pause.CondErr = err
+			} else if !hit {
+				dbg.Unlock()
+				return
+			}
+		}
+	}
+
+	pause.Vertices = snapshot()
+	dbg.paused = pause
+	ctx, pauses := dbg.ctx, dbg.pauses
+
+	dbg.Unlock()
+
+	// resume can come before the pause is received
+	select {
+	case pauses <- pause:
+	case <-dbg.resume:
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case <-dbg.resume:
+	case <-ctx.Done():
+	}
+}
+
+// ServeCLI reads debug commands from r line by line while paused, and writes output to w.
+// It returns when the run finished, the run continues to end if r reaches EOF.
+func (dbg *Debugger) ServeCLI(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	eof := false
+
+	for pause := range dbg.Pauses() {
+		if eof {
+			dbg.Continue()
+			continue
+		}
+
+		fmt.Fprintf(w, "paused before %s\n", pause.Node)
+
+		if pause.CondErr != nil {
+			fmt.Fprintf(w, "breakpoint condition failed: %v\n", pause.CondErr)
+		}
+
+		for resumed := false; !resumed; {
+			fmt.Fprint(w, "(ograph) ")
+
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return err
+				}
+
+				eof = true
+				dbg.Continue()
+				break
+			}
+
+			resumed = dbg.execCommand(strings.Fields(scanner.Text()), w)
+		}
+	}
+
+	fmt.Fprintln(w, "run finished")
+
+	return nil
+}
+
+// execCommand runs a cli command, it returns true if the run is resumed.
+func (dbg *Debugger) execCommand(args []string, w io.Writer) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch cmd := args[0]; {
+	case cmd == "s" || cmd == "step":
+		return dbg.Step() == nil
+	case cmd == "c" || cmd == "continue":
+		return dbg.Continue() == nil
+	case (cmd == "b" || cmd == "break") && len(args) >= 2:
+		if err := dbg.SetBreakpoint(args[1], strings.Join(args[2:], " ")); err != nil {
+			fmt.Fprintf(w, "set breakpoint failed: %v\n", err)
+		}
+	case cmd == "clear" && len(args) == 2:
+		dbg.ClearBreakpoint(args[1])
+	case cmd == "bl" || cmd == "breakpoints":
+		breakpoints := dbg.Breakpoints()
+
+		for _, node := range slices.Sorted(maps.Keys(breakpoints)) {
+			fmt.Fprintf(w, "%s %s\n", node, breakpoints[node])
+		}
+	case cmd == "ls" || cmd == "list":
+		vertices, err := dbg.Vertices()
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return false
+		}
+
+		for _, status := range []string{VertexRunning, VertexReady, VertexDone, VertexTodo} {
+			var names []string
+
+			for _, name := range slices.Sorted(maps.Keys(vertices)) {
+				if vertices[name] == status {
+					names = append(names, name)
+				}
+			}
+
+			fmt.Fprintf(w, "%s: %s\n", status, strings.Join(names, ", "))
+		}
+	case cmd == "get" && len(args) == 2:
+		val, ok := dbg.State().Get(args[1])
+		if !ok {
+			fmt.Fprintf(w, "%s not found\n", args[1])
+		} else {
+			fmt.Fprintf(w, "%s = (%T)%v\n", args[1], val, val)
+		}
+	case cmd == "set" && len(args) >= 3:
+		raw := strings.Join(args[2:], " ")
+
+		// value is decoded as json, or used as string if it's not json
+		var val any
+		if err := json.Unmarshal([]byte(raw), &val); err != nil {
+			val = raw
+		}
+
+		dbg.State().Set(args[1], val)
+	default:
+		fmt.Fprintln(w, "commands: step(s), continue(c), break(b) <node> [cond], clear <node>, "+
+			"breakpoints(bl), list(ls), get <key>, set <key> <json>")
+	}
+
+	return false
+}
+
+func NewDebugger(pipeline *Pipeline) *Debugger {
+	return &Debugger{
+		pipeline:    pipeline,
+		breakpoints: make(map[string]breakpoint),
+		resume:      make(chan struct{}),
+	}
+}
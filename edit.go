@@ -0,0 +1,84 @@
+package ograph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrElementNotFound error = errors.New("element not found")
+var ErrElementExists error = errors.New("element already exists")
+var ErrEdgeNotFound error = errors.New("edge not found")
+
+// Unregister removes element and all edges connected to it.
+func (pipeline *Pipeline) Unregister(name string) error {
+	if pipeline.elements[name] == nil {
+		return fmt.Errorf("%w, name: %s", ErrElementNotFound, name)
+	}
+
+	pipeline.graph.RemoveVertex(name)
+	delete(pipeline.elements, name)
+
+	pipeline.dangling = slices.DeleteFunc(pipeline.dangling, func(edge [2]string) bool {
+		return edge[0] == name || edge[1] == name
+	})
+
+	pipeline.ResetPool()
+
+	return nil
+}
+
+func (pipeline *Pipeline) RemoveEdge(from, to string) error {
+	if !pipeline.graph.RemoveEdge(from, to) {
+		return fmt.Errorf("%w, edge: %s -> %s", ErrEdgeNotFound, from, to)
+	}
+
+	pipeline.ResetPool()
+
+	return nil
+}
+
+// Replace puts e in place of the registered element, edges of the old element are kept.
+// e can have a different name, as long as the name is not used by other elements.
+func (pipeline *Pipeline) Replace(name string, e *Element) error {
+	if pipeline.elements[name] == nil {
+		return fmt.Errorf("%w, name: %s", ErrElementNotFound, name)
+	}
+
+	if e.Name != name && pipeline.elements[e.Name] != nil {
+		return fmt.Errorf("%w, name: %s", ErrElementExists, e.Name)
+	}
+
+	pipeline.graph.ReplaceVertex(name, e.Name, e)
+	delete(pipeline.elements, name)
+	pipeline.elements[e.Name] = e
+
+	for i := range pipeline.dangling {
+		for j, end := range pipeline.dangling[i] {
+			if end == name {
+				pipeline.dangling[i][j] = e.Name
+			}
+		}
+	}
+
+	pipeline.ResetPool()
+
+	return nil
+}
+
+// InsertBetween replaces edge from->to by from->e->to, e is registered if it is not.
+func (pipeline *Pipeline) InsertBetween(from, to string, e *Element) error {
+	if registered := pipeline.elements[e.Name]; registered != nil && registered != e {
+		return fmt.Errorf("%w, name: %s", ErrElementExists, e.Name)
+	}
+
+	if !pipeline.graph.InsertVertex(from, to, e.Name, e) {
+		return fmt.Errorf("%w, edge: %s -> %s", ErrEdgeNotFound, from, to)
+	}
+
+	pipeline.elements[e.Name] = e
+
+	pipeline.ResetPool()
+
+	return nil
+}
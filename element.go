@@ -2,6 +2,8 @@ package ograph
 
 import (
 	"context"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/symphony09/ograph/internal"
@@ -17,6 +19,10 @@ type Element struct {
 	DefaultImpl string         `json:"DefaultImpl,omitempty"`
 	Priority    int            `json:"Priority,omitempty"`
 
+	// NonIdempotent node is not run again automatically when a run is resumed from journal,
+	// if it was interrupted while running.
+	NonIdempotent bool `json:"NonIdempotent,omitempty"`
+
 	WrapperAlias map[string]string `json:"WrapperAlias,omitempty"`
 
 	Singleton ogcore.Node `json:"-"`
@@ -36,6 +42,11 @@ func (e *Element) AsVirtual() *Element {
 	return e
 }
 
+func (e *Element) AsNonIdempotent() *Element {
+	e.NonIdempotent = true
+	return e
+}
+
 func (e *Element) UseFactory(name string, subElements ...*Element) *Element {
 	e.FactoryName = name
 	e.SubElements = append(e.SubElements, subElements...)
@@ -125,6 +136,73 @@ func (e *Element) GetPriority() int {
 	return e.Priority
 }
 
+// Clone returns a deep copy of element, including params, wrappers and sub elements.
+// Singleton of *Pipeline is cloned deeply, Singleton implementing ogcore.Cloneable is cloned by its Clone method,
+// other Singleton nodes can't be copied and are shared between the element and its clone.
+func (e *Element) Clone() *Element {
+	return e.clone(make(map[*Pipeline]*Pipeline))
+}
+
+func (e *Element) clone(pipelines map[*Pipeline]*Pipeline) *Element {
+	clone := *e
+
+	clone.Wrappers = slices.Clone(e.Wrappers)
+	clone.ParamsMap = cloneParams(e.ParamsMap)
+	clone.WrapperAlias = maps.Clone(e.WrapperAlias)
+
+	if e.SubElements != nil {
+		clone.SubElements = make([]*Element, 0, len(e.SubElements))
+
+		for _, subElem := range e.SubElements {
+			clone.SubElements = append(clone.SubElements, subElem.clone(pipelines))
+		}
+	}
+
+	if subPipeline, ok := e.Singleton.(*Pipeline); ok {
+		clone.Singleton = subPipeline.clone(pipelines)
+	} else if cloneable, ok := e.Singleton.(ogcore.Cloneable); ok {
+		clone.Singleton = cloneable.Clone()
+	}
+
+	return &clone
+}
+
+// cloneParams copies params and nested map[string]any and []any values, other values are copied shallowly.
+func cloneParams(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+
+	clone := make(map[string]any, len(params))
+
+	for k, v := range params {
+		clone[k] = cloneParam(v)
+	}
+
+	return clone
+}
+
+func cloneParam(param any) any {
+	switch v := param.(type) {
+	case map[string]any:
+		return cloneParams(v)
+	case []any:
+		if v == nil {
+			return v
+		}
+
+		clone := make([]any, len(v))
+
+		for i := range v {
+			clone[i] = cloneParam(v[i])
+		}
+
+		return clone
+	}
+
+	return param
+}
+
 type PGraph = internal.Graph[*Element]
 
 func NewElement(name string) *Element {
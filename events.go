@@ -0,0 +1,145 @@
+package ograph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/symphony09/eventd"
+	"github.com/symphony09/ograph/internal"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+// Lifecycle events emitted by framework on pipeline event bus.
+// node.end is emitted when node succeeded, node.error when it failed.
+//
+// Emitting an event costs a goroutine for each subscription of the bus, so pipeline.* and node.* events are only
+// emitted in runs of pipelines which have subscriptions matching them when the run starts, or nested in such runs.
+const (
+	EventPipelineStart = internal.EventPipelineStart
+	EventPipelineEnd   = internal.EventPipelineEnd
+	EventNodeStart     = internal.EventNodeStart
+	EventNodeEnd       = internal.EventNodeEnd
+	EventNodeError     = internal.EventNodeError
+	EventNodeRetry     = "node.retry"
+	EventNodeTimeout   = "node.timeout"
+)
+
+// LifecycleEvent is the payload of lifecycle events, subscribe them with SubscribePayload[*LifecycleEvent].
+type LifecycleEvent struct {
+	Node     string
+	RunID    string
+	Duration time.Duration
+	Err      error
+
+	// Attempt is the number of the coming attempt for node.retry.
+	Attempt int
+}
+
+// EmitLifecycle emits lifecycle event on bus, RunID is taken from ctx if it's not set.
+// It does nothing if bus is nil, or lifecycle events are not listened in the run of ctx.
+func EmitLifecycle(ctx context.Context, bus *eventd.EventBus[ogcore.State], event string, state ogcore.State, payload *LifecycleEvent) {
+	if !lifecycleListened(ctx) {
+		return
+	}
+
+	if payload.RunID == "" {
+		payload.RunID = ogcore.RunIDFrom(ctx)
+	}
+
+	EmitPayload(bus, event, state, payload)
+}
+
+// EmitPayload emits event with payload alongside state, subscribers get the payload by PayloadOf or SubscribePayload.
+// It does nothing if bus is nil.
+func EmitPayload(bus *eventd.EventBus[ogcore.State], event string, state ogcore.State, payload any) {
+	if bus == nil {
+		return
+	}
+
+	bus.Emit(event, &ogcore.Event{State: state, Payload: payload})
+}
+
+// PayloadOf returns payload of event object if it's of type P.
+func PayloadOf[P any](obj ogcore.State) (payload P, ok bool) {
+	if event, isEvent := obj.(*ogcore.Event); isEvent {
+		payload, ok = event.Payload.(P)
+	}
+
+	return
+}
+
+// SubscribePayload subscribes events of pipeline whose payload is of type P, other events are skipped.
+// State passed to callback is the state of the run which emitted the event.
+func SubscribePayload[P any](pipeline *Pipeline, callback func(event string, state ogcore.State, payload P) bool,
+	ops ...eventd.Op) (cancel func(), err error) {
+
+	return pipeline.Subscribe(func(event string, obj ogcore.State) bool {
+		payload, ok := PayloadOf[P](obj)
+		if !ok {
+			return true
+		}
+
+		return callback(event, obj.(*ogcore.Event).State, payload)
+	}, ops...)
+}
+
+// BaseEventWrapper is BaseWrapper with the pipeline event bus attached.
+type BaseEventWrapper struct {
+	BaseWrapper
+	*eventd.EventBus[ogcore.State]
+}
+
+func (wrapper *BaseEventWrapper) AttachBus(bus *eventd.EventBus[ogcore.State]) {
+	wrapper.EventBus = bus
+}
+
+// EmitPayload emits event with payload alongside state, it does nothing if bus is not attached.
+func (wrapper *BaseEventWrapper) EmitPayload(event string, state ogcore.State, payload any) {
+	EmitPayload(wrapper.EventBus, event, state, payload)
+}
+
+var lifecycleEvents = []string{
+	EventPipelineStart, EventPipelineEnd, EventNodeStart, EventNodeEnd, EventNodeError, EventNodeRetry, EventNodeTimeout,
+}
+
+type lifecycleListenedKey struct{}
+
+// matchLifecycle reports whether subscription with ops matches any lifecycle event.
+func matchLifecycle(ops []eventd.Op) bool {
+	option := new(eventd.SubscribeOption)
+
+	for _, op := range ops {
+		op(option)
+	}
+
+	for _, pattern := range option.Events {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+
+		for _, event := range lifecycleEvents {
+			if r.MatchString(event) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lifecycleListened reports whether lifecycle events of the run with ctx are listened by outer pipelines.
+func lifecycleListened(ctx context.Context) bool {
+	listened, _ := ctx.Value(lifecycleListenedKey{}).(bool)
+	return listened
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/symphony09/eventd"
 	"github.com/symphony09/ograph"
 	"github.com/symphony09/ograph/ogcore"
 	"github.com/symphony09/ograph/ogimpl"
@@ -85,6 +89,85 @@ func TestWrapper_Retry(t *testing.T) {
 	}
 }
 
+var errTemporary = errors.New("temporary error")
+
+func TestWrapper_RetryBackoff(t *testing.T) {
+	var attempts []int
+
+	flaky := &ograph.BaseNode{Action: func(ctx context.Context, state ogcore.State) error {
+		attempts = append(attempts, ogimpl.RetryAttemptFrom(ctx))
+
+		if len(attempts) < 3 {
+			return fmt.Errorf("attempt %d: %w", len(attempts), errTemporary)
+		}
+
+		return nil
+	}}
+
+	pipeline := ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Flaky").UseNode(flaky).
+		Wrap(ogimpl.Retry).
+		Params("MaxRetryTimes", 5).
+		Params("RetryDelay", "5ms").
+		Params("BackoffFactor", 2.0).
+		Params("Jitter", 0.5).
+		Params("RetryOn", []error{errTemporary}))
+
+	start := time.Now()
+
+	if err := pipeline.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+
+	if !slices.Equal(attempts, []int{1, 2, 3}) {
+		t.Errorf("unexpected attempts: %v", attempts)
+	}
+
+	// delays are at least 2.5ms and 5ms with jitter
+	if elapsed := time.Since(start); elapsed < 7*time.Millisecond {
+		t.Errorf("retried too fast: %s", elapsed)
+	}
+
+	// errors not matching retry conditions are returned at once
+	attempts = nil
+
+	permanent := &ograph.BaseNode{Action: func(ctx context.Context, state ogcore.State) error {
+		attempts = append(attempts, ogimpl.RetryAttemptFrom(ctx))
+		return errors.New("permanent error")
+	}}
+
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Permanent").UseNode(permanent).
+		Wrap(ogimpl.Retry).
+		Params("MaxRetryTimes", 5).
+		Params("RetryIf", ogimpl.IsErrorType[*time.ParseError]).
+		Params("RetryIfExpr", `err contains "temporary" && attempt < limit`))
+
+	state := ograph.NewState()
+	state.Set("limit", 3)
+
+	if err := pipeline.Run(context.TODO(), state); err == nil || len(attempts) != 1 {
+		t.Errorf("expect no retry, err: %v, attempts: %v", err, attempts)
+	}
+
+	// waiting for retry stops when ctx is done
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Loser").UseNode(&Loser{}).
+		Wrap(ogimpl.Retry).
+		Params("MaxRetryTimes", 5).
+		Params("RetryDelay", time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := pipeline.Run(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got: %v", err)
+	}
+}
+
 type Sloth struct {
 	ograph.BaseNode
 }
@@ -162,3 +245,638 @@ func TestWrapper_Customize(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestWrapper_RateLimit(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	// buckets live as long as the program, use a new key for each test run.
+	key := fmt.Sprintf("example_api_%d", time.Now().UnixNano())
+
+	// the two elements share a bucket of 2 tokens, which is refilled at 20 tokens per second.
+	api1 := ograph.NewElement("API1").UseFn(func() error { return nil }).
+		Apply(ogimpl.RateLimitOp(key, 20, 2, ogimpl.RateLimitFailFast))
+	api2 := ograph.NewElement("API2").UseFn(func() error { return nil }).
+		Apply(ogimpl.RateLimitOp(key, 20, 2, ogimpl.RateLimitFailFast))
+
+	pipeline.Register(api1).Register(api2, ograph.Rely(api1))
+
+	if err := pipeline.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := pipeline.Run(context.TODO(), nil); !errors.Is(err, ogimpl.ErrRateLimited) {
+		t.Errorf("got err = %v, want %v", err, ogimpl.ErrRateLimited)
+	}
+
+	waiting := ograph.NewPipeline()
+	waiting.Register(ograph.NewElement("API").UseFn(func() error { return nil }).
+		Apply(ogimpl.RateLimitOp(key, 20, 2, ogimpl.RateLimitWait)))
+
+	// wait for tokens refilled
+	start := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if err := waiting.Run(context.TODO(), nil); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if cost := time.Since(start); cost < 80*time.Millisecond {
+		t.Errorf("got time cost %s, want at least 80ms", cost)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := waiting.Run(ctx, nil); !errors.Is(err, ogimpl.ErrRateLimited) {
+		t.Errorf("got err = %v, want %v", err, ogimpl.ErrRateLimited)
+	}
+
+	// the bucket is used with another rate
+	mismatched := ograph.NewPipeline()
+	mismatched.Register(ograph.NewElement("API").UseFn(func() error { return nil }).
+		Apply(ogimpl.RateLimitOp(key, 10, 2, ogimpl.RateLimitWait)))
+
+	if err := mismatched.Run(context.TODO(), nil); err == nil || errors.Is(err, ogimpl.ErrRateLimited) {
+		t.Errorf("got err = %v, want mismatch error", err)
+	}
+
+	// without key, buckets are scoped to pipelines
+	newScoped := func() *ograph.Pipeline {
+		p := ograph.NewPipeline()
+		p.Register(ograph.NewElement("Scoped").UseFn(func() error { return nil }).
+			Wrap(ogimpl.RateLimit).Params("Rate", 1).Params("Mode", ogimpl.RateLimitFailFast))
+		return p
+	}
+
+	scoped1, scoped2 := newScoped(), newScoped()
+
+	if err := scoped1.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := scoped2.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := scoped1.Run(context.TODO(), nil); !errors.Is(err, ogimpl.ErrRateLimited) {
+		t.Errorf("got err = %v, want %v", err, ogimpl.ErrRateLimited)
+	}
+
+	unknownMode := ograph.NewPipeline()
+	unknownMode.Register(ograph.NewElement("API").UseFn(func() error { return nil }).
+		Wrap(ogimpl.RateLimit).Params("Rate", 1).Params("Mode", "Block"))
+
+	if err := unknownMode.Run(context.TODO(), nil); err == nil {
+		t.Error("got nil err, want unknown mode error")
+	}
+}
+
+func TestWrapper_CircuitBreaker(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	key := fmt.Sprintf("example_dependency_%d", time.Now().UnixNano())
+
+	var calls int
+	down := true
+
+	dependency := ograph.NewElement("Dependency").UseFn(func() error {
+		calls++
+		if down {
+			return errors.New("dependency is down")
+		}
+		return nil
+	}).Apply(ogimpl.CircuitBreakerOp(key, 2, 50*time.Millisecond))
+
+	pipeline.Register(dependency)
+
+	var transitions []string
+
+	ograph.SubscribePayload(pipeline, func(event string, state ogcore.State, transition *ogimpl.CircuitTransition) bool {
+		transitions = append(transitions, transition.From+" -> "+transition.To)
+		return true
+	}, eventd.On(`^circuit\.`))
+
+	for i := 0; i < 4; i++ {
+		pipeline.Run(context.TODO(), nil)
+	}
+
+	// breaker opens after 2 failures, the dependency is not called any more.
+	err := pipeline.Run(context.TODO(), nil)
+
+	var openErr *ogimpl.CircuitOpenError
+	if !errors.Is(err, ogimpl.ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.Key != key {
+		t.Errorf("got err = %v, want %v", err, ogimpl.ErrCircuitOpen)
+	}
+
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+
+	// probe after open duration, the breaker closes if it succeeds.
+	down = false
+	time.Sleep(60 * time.Millisecond)
+
+	if err := pipeline.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+
+	want := []string{"closed -> open", "open -> half_open", "half_open -> closed"}
+
+	if !slices.Equal(transitions, want) {
+		t.Errorf("got transitions %v, want %v", transitions, want)
+	}
+
+	// the breaker is used with other settings
+	mismatched := ograph.NewPipeline()
+	mismatched.Register(ograph.NewElement("Dependency").UseFn(func() error { return nil }).
+		Apply(ogimpl.CircuitBreakerOp(key, 3, 50*time.Millisecond)))
+
+	if err := mismatched.Run(context.TODO(), nil); err == nil {
+		t.Error("got nil err, want mismatch error")
+	}
+
+	// without key, breakers are scoped to pipelines
+	newScoped := func(fail bool) *ograph.Pipeline {
+		p := ograph.NewPipeline()
+		p.Register(ograph.NewElement("Scoped").UseFn(func() error {
+			if fail {
+				return errors.New("scoped dependency is down")
+			}
+			return nil
+		}).Wrap(ogimpl.CircuitBreaker).Params("FailureThreshold", 1).Params("OpenDuration", time.Minute))
+		return p
+	}
+
+	failing, healthy := newScoped(true), newScoped(false)
+
+	failing.Run(context.TODO(), nil)
+
+	if err := failing.Run(context.TODO(), nil); !errors.Is(err, ogimpl.ErrCircuitOpen) {
+		t.Errorf("got err = %v, want %v", err, ogimpl.ErrCircuitOpen)
+	}
+
+	if err := healthy.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWrapper_Batch(t *testing.T) {
+	var batches atomic.Int32
+
+	double := func(ctx context.Context, items []any) ([]ogimpl.BatchResult, error) {
+		batches.Add(1)
+
+		results := make([]ogimpl.BatchResult, len(items))
+
+		for i, item := range items {
+			if n, ok := item.(int); ok && n >= 0 {
+				results[i].Value = n * 2
+			} else {
+				results[i].Err = fmt.Errorf("invalid item: %v", item)
+			}
+		}
+
+		return results, nil
+	}
+
+	pipeline := ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Double").
+		UsePrivateFactory(func() ogcore.Node { return &ograph.BaseNode{} }).
+		Apply(ogimpl.BatchOp(fmt.Sprintf("double-%d", time.Now().UnixNano()), 5, 20*time.Millisecond)).
+		Params("BatchFn", double).
+		Params("ItemKey", "n").
+		Params("ResultKey", "doubled"))
+
+	var wg sync.WaitGroup
+
+	for i := -1; i < 12; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			state := ograph.NewState()
+			state.Set("n", i)
+
+			err := pipeline.Run(context.TODO(), state)
+
+			if i < 0 {
+				if err == nil {
+					t.Errorf("expect error of item %d", i)
+				}
+			} else if doubled, _ := state.Get("doubled"); err != nil || doubled != i*2 {
+				t.Errorf("item %d: unexpected result %v, err: %v", i, doubled, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// 13 items in batches of at most 5
+	if n := batches.Load(); n < 3 || n > 6 {
+		t.Errorf("unexpected batch count: %d", n)
+	}
+
+	// without BatchKey, pipelines with elements of the same name don't share batches
+	negate := func(ctx context.Context, items []any) ([]ogimpl.BatchResult, error) {
+		results := make([]ogimpl.BatchResult, len(items))
+
+		for i, item := range items {
+			results[i].Value = -item.(int)
+		}
+
+		return results, nil
+	}
+
+	newPipeline := func(key string, fn func(ctx context.Context, items []any) ([]ogimpl.BatchResult, error)) *ograph.Pipeline {
+		return ograph.NewPipeline().Register(ograph.NewElement("Compute").
+			UsePrivateFactory(func() ogcore.Node { return &ograph.BaseNode{} }).
+			Apply(ogimpl.BatchOp(key, 5, 20*time.Millisecond)).
+			Params("BatchFn", fn).
+			Params("ItemKey", "n").
+			Params("ResultKey", "result"))
+	}
+
+	doubler, negater := newPipeline("", double), newPipeline("", negate)
+
+	for _, c := range []struct {
+		pipeline *ograph.Pipeline
+		want     int
+	}{{doubler, 6}, {negater, -3}, {doubler, 6}, {negater, -3}} {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			state := ograph.NewState()
+			state.Set("n", 3)
+
+			if err := c.pipeline.Run(context.TODO(), state); err != nil {
+				t.Error(err)
+			} else if result, _ := state.Get("result"); result != c.want {
+				t.Errorf("expect %d, got: %v", c.want, result)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// wrappers sharing BatchKey should have the same batch settings
+	key := fmt.Sprintf("compute-%d", time.Now().UnixNano())
+
+	state := ograph.NewState()
+	state.Set("n", 1)
+
+	entered, hold := make(chan struct{}), make(chan struct{})
+
+	blockingDouble := func(ctx context.Context, items []any) ([]ogimpl.BatchResult, error) {
+		close(entered)
+		<-hold
+		return double(ctx, items)
+	}
+
+	doubled := make(chan error, 1)
+
+	go func() {
+		state := ograph.NewState()
+		state.Set("n", 1)
+
+		doubled <- newPipeline(key, blockingDouble).Run(context.TODO(), state)
+	}()
+
+	<-entered
+
+	if err := newPipeline(key, negate).Run(context.TODO(), state); !errors.Is(err, ogimpl.ErrBatchMismatch) {
+		t.Errorf("expect batch mismatch, got: %v", err)
+	}
+
+	close(hold)
+
+	if err := <-doubled; err != nil {
+		t.Error(err)
+	}
+
+	// runs whose batch has started wait for it even if their ctx is done
+	entered, hold = make(chan struct{}), make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	go func() {
+		<-entered
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		close(hold)
+	}()
+
+	start := time.Now()
+
+	if err := newPipeline(key+"-canceled", blockingDouble).Run(ctx, state); err != nil {
+		t.Error(err)
+	} else if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expect canceled run waits for started batch, elapsed: %s", elapsed)
+	}
+
+	// batcher is removed when no run uses it
+	if err := newPipeline(key, negate).Run(context.TODO(), state); err != nil {
+		t.Error(err)
+	} else if result, _ := state.Get("result"); result != -1 {
+		t.Errorf("expect -1, got: %v", result)
+	}
+}
+
+func TestWrapper_SingleFlight(t *testing.T) {
+	var executions atomic.Int32
+
+	release := make(chan struct{})
+
+	pipeline := ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Report").
+		UsePrivateFactory(func() ogcore.Node {
+			return ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+				executions.Add(1)
+
+				select {
+				case <-release:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				user, _ := state.Get("user")
+				state.Set("report", fmt.Sprintf("report of %v", user))
+				return nil
+			})
+		}).
+		Apply(ogimpl.SingleFlightOp("user")).
+		Params("GroupKey", fmt.Sprintf("report-%d", time.Now().UnixNano())))
+
+	// leader is canceled while followers are waiting, then one of them starts a new execution
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+
+	leaderErr := make(chan error, 1)
+
+	go func() {
+		state := ograph.NewState()
+		state.Set("user", "alice")
+		leaderErr <- pipeline.Run(leaderCtx, state)
+	}()
+
+	for executions.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			state := ograph.NewState()
+			state.Set("user", "alice")
+
+			if err := pipeline.Run(context.TODO(), state); err != nil {
+				t.Error(err)
+			} else if report, _ := state.Get("report"); report != "report of alice" {
+				t.Errorf("unexpected report: %v", report)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	cancelLeader()
+
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expect leader canceled, got: %v", err)
+	}
+
+	for executions.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	if n := executions.Load(); n != 2 {
+		t.Errorf("expect executed twice, got: %d", n)
+	}
+
+	// without GroupKey, pipelines with elements of the same name don't share executions
+	started := make(chan struct{})
+	release = make(chan struct{})
+
+	newPipeline := func(val string, blocking bool) *ograph.Pipeline {
+		return ograph.NewPipeline().Register(ograph.NewElement("Write").
+			UsePrivateFactory(func() ogcore.Node {
+				return ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+					if blocking {
+						close(started)
+						<-release
+					}
+
+					state.Set("val", val)
+					return nil
+				})
+			}).
+			Wrap(ogimpl.SingleFlight))
+	}
+
+	slow, fast := newPipeline("slow", true), newPipeline("fast", false)
+
+	slowState := ograph.NewState()
+	slowErr := make(chan error, 1)
+
+	go func() { slowErr <- slow.Run(context.TODO(), slowState) }()
+
+	<-started
+
+	fastState := ograph.NewState()
+
+	if err := fast.Run(context.TODO(), fastState); err != nil {
+		t.Error(err)
+	} else if val, _ := fastState.Get("val"); val != "fast" {
+		t.Errorf("expect own execution, got: %v", val)
+	}
+
+	close(release)
+
+	if err := <-slowErr; err != nil {
+		t.Error(err)
+	} else if val, _ := slowState.Get("val"); val != "slow" {
+		t.Errorf("expect own execution, got: %v", val)
+	}
+
+	// the execution runs in the starting run, its node is not reused by the next run before it ends
+	var busy, overlapped atomic.Bool
+
+	pooled := ograph.NewPipeline()
+	pooled.SetPoolCache(1, false)
+
+	pooled.Register(ograph.NewElement("Busy").
+		UsePrivateFactory(func() ogcore.Node {
+			return ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+				if !busy.CompareAndSwap(false, true) {
+					overlapped.Store(true)
+				}
+
+				time.Sleep(30 * time.Millisecond)
+				busy.Store(false)
+				return nil
+			})
+		}).
+		Apply(ogimpl.SingleFlightOp("id")))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Millisecond)
+	defer cancel()
+
+	state := ograph.NewState()
+	state.Set("id", 1)
+	pooled.Run(ctx, state)
+
+	state = ograph.NewState()
+	state.Set("id", 2)
+
+	if err := pooled.Run(context.TODO(), state); err != nil {
+		t.Error(err)
+	}
+
+	if overlapped.Load() {
+		t.Error("expect node not used by the next run while executing")
+	}
+}
+
+func TestWrapper_Loop(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Fetch").
+		UseNode(ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			i := ogimpl.LoopIterationFrom(ctx)
+
+			switch {
+			case i == 1:
+				return fmt.Errorf("skip page %d: %w", i, ogimpl.ErrContinue)
+			case i == 4:
+				return ogimpl.ErrBreak
+			}
+
+			state.Set("page", fmt.Sprintf("page-%d", i))
+			return nil
+		})).
+		Apply(ogimpl.LoopOp(10)).
+		Params("IterationKey", "i").
+		Params("ResultKey", "page").
+		Params("ResultsKey", "pages"))
+
+	state := ograph.NewState()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := ograph.LoadState[[]any](state, "pages")
+	if !slices.Equal(pages, []any{"page-0", "page-2", "page-3"}) {
+		t.Errorf("unexpected pages: %v", pages)
+	}
+
+	if i, _ := state.Get("i"); i != 4 {
+		t.Errorf("unexpected iteration: %v", i)
+	}
+
+	// condition loop exceeds max iterations
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Poll").
+		UseFn(func() error { return nil }).
+		Apply(ogimpl.LoopWhileOp("ready != true")).
+		Params("MaxIterations", 3))
+
+	if err := pipeline.Run(context.TODO(), nil); !errors.Is(err, ogimpl.ErrMaxIterations) {
+		t.Errorf("expect ErrMaxIterations, got %v", err)
+	}
+
+	// condition loops are capped by default
+	var capped int
+
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Poll").
+		UseFn(func() error { capped++; return nil }).
+		Apply(ogimpl.LoopWhileOp("ready != true")))
+
+	if err := pipeline.Run(context.TODO(), nil); !errors.Is(err, ogimpl.ErrMaxIterations) || capped != 1000 {
+		t.Errorf("expect ErrMaxIterations after 1000 polls, got %v after %d polls", err, capped)
+	}
+
+	// expression failure is returned instead of panic
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Poll").
+		UseFn(func() error { return nil }).
+		Apply(ogimpl.LoopWhileOp("count + 1")))
+
+	if err := pipeline.Run(context.TODO(), nil); err == nil {
+		t.Error("expect error of non-bool condition")
+	}
+
+	// waiting interval is canceled with ctx
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Tick").
+		UseFn(func() error { return nil }).
+		Apply(ogimpl.LoopOp(3)).
+		Params("LoopInterval", time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := pipeline.Run(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Error("loop interval is not canceled with ctx")
+	}
+
+	// condition is checked after waiting interval
+	var polls atomic.Int32
+
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Poll").
+		UseFn(func() error { polls.Add(1); return nil }).
+		Apply(ogimpl.LoopWhileOp("ready != true")).
+		Params("LoopInterval", 50*time.Millisecond))
+
+	state = ograph.NewState()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		state.Set("ready", true)
+	}()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Error(err)
+	} else if n := polls.Load(); n != 1 {
+		t.Errorf("expect polled once, got: %d", n)
+	}
+
+	// results can't be collected without ResultKey
+	pipeline = ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Fetch").
+		UseFn(func() error { return nil }).
+		Apply(ogimpl.LoopOp(3)).
+		Params("ResultsKey", "pages"))
+
+	if err := pipeline.Run(context.TODO(), nil); err == nil {
+		t.Error("expect error of ResultsKey without ResultKey")
+	}
+}
@@ -2,8 +2,12 @@ package example
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -46,6 +50,86 @@ func TestCluster_Choose(t *testing.T) {
 	} else if chosen != "B" {
 		t.Error(errors.New("node B not ran when choose_name equals b"))
 	}
+
+	// singleton clusters are not initialized, ChooseFn is used directly
+	singleton := &ogimpl.ChooseCluster{ChooseFn: func(ctx context.Context, state ogcore.State) int {
+		return 1
+	}}
+
+	singleton.Join([]ogcore.Node{
+		ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			chosen = "D"
+			return nil
+		}),
+		ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			chosen = "E"
+			return nil
+		}),
+	})
+
+	chosen = ""
+
+	if err := ograph.NewPipeline().Register(ograph.NewElement("S").UseNode(singleton)).
+		Run(context.TODO(), ograph.NewState()); err != nil {
+		t.Error(err)
+	} else if chosen != "D" {
+		t.Errorf("expect node D chosen by singleton cluster, got: %q", chosen)
+	}
+}
+
+func TestCluster_Switch(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	var lock sync.Mutex
+	var chosen []string
+
+	handler := func(name string) *ograph.Element {
+		return ograph.NewElement(name).UseFn(func() error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			chosen = append(chosen, name)
+			return nil
+		})
+	}
+
+	pipeline.Register(ograph.NewElement("Route").
+		Apply(ogimpl.SwitchOp("kind", "Fallback",
+			handler("Image"), handler("Video"), handler("Thumbnail"), handler("Fallback"))).
+		Params("Cases", map[string]string{"Image": "media", "Video": "media", "Thumbnail": "media"}).
+		Params("MultiMatch", true))
+
+	for _, c := range []struct {
+		kind   any
+		chosen []string
+	}{
+		{"Video", []string{"Video"}},
+		{"media", []string{"Image", "Thumbnail", "Video"}},
+		{"text", []string{"Fallback"}},
+	} {
+		chosen = nil
+
+		state := ograph.NewState()
+		state.Set("kind", c.kind)
+
+		if err := pipeline.Run(context.TODO(), state); err != nil {
+			t.Error(err)
+		}
+
+		slices.Sort(chosen)
+
+		if !slices.Equal(chosen, c.chosen) {
+			t.Errorf("kind %v: expect %v chosen, got: %v", c.kind, c.chosen, chosen)
+		}
+	}
+
+	// expr errors are returned instead of panic
+	state := ograph.NewState()
+	state.Set("kind", 1)
+
+	if err := pipeline.Run(context.TODO(), state); err == nil {
+		t.Error("expect error for non-string case")
+	}
 }
 
 func TestCluster_Parallel(t *testing.T) {
@@ -103,6 +187,72 @@ func TestCluster_Race(t *testing.T) {
 	} else {
 		winner, _ := state.Get("Winner")
 		fmt.Println("Winner is", winner)
+
+		// rabbit is awaited, its write after turtle won is dropped
+		if winner != "Turtle" {
+			t.Errorf("expect turtle wins, got: %v", winner)
+		}
+
+		if winner, _ := state.Get("Race_winner"); winner != "Turtle" {
+			t.Errorf("expect winner recorded, got: %v", winner)
+		}
+	}
+}
+
+func TestCluster_RaceIsolation(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	slow := ograph.NewElement("Slow").UseNode(&Backend{Cost: time.Second})
+	fast := ograph.NewElement("Fast").UseNode(&Backend{Cost: time.Millisecond})
+
+	pipeline.Register(ograph.NewElement("Race").
+		UseFactory(ogimpl.Race, slow, fast).
+		Params("StateIsolation", true).
+		Params("WinnerKey", "Winner"))
+
+	state := ograph.NewState()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	// writes of fast are committed, writes of slow are discarded
+	if backend, _ := state.Get("Backend"); backend != "Fast" {
+		t.Errorf("expect writes of winner, got: %v", backend)
+	}
+
+	if winner, _ := state.Get("Winner"); winner != "Fast" {
+		t.Errorf("expect winner recorded, got: %v", winner)
+	}
+}
+
+func TestCluster_RaceAwaitLosers(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	var stubbornDone atomic.Bool
+
+	// stubborn ignores ctx, the cluster waits for it after the winner is decided
+	stubborn := ograph.NewElement("Stubborn").UseFn(func() error {
+		time.Sleep(50 * time.Millisecond)
+		stubbornDone.Store(true)
+		return nil
+	})
+	fast := ograph.NewElement("Fast").UseNode(&Backend{Cost: time.Millisecond})
+
+	pipeline.Register(ograph.NewElement("Race").UseFactory(ogimpl.Race, stubborn, fast))
+
+	state := ograph.NewState()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	if !stubbornDone.Load() {
+		t.Error("expect loser awaited before cluster returns")
+	}
+
+	if winner, _ := state.Get("Race_winner"); winner != "Fast" {
+		t.Errorf("expect winner recorded, got: %v", winner)
 	}
 }
 
@@ -147,3 +297,327 @@ func TestCluster_Customize(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+var errOriginDown = errors.New("origin is down")
+
+func TestCluster_Fallback(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	pipeline.RegisterFactory("Origin", func() ogcore.Node {
+		return ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			return fmt.Errorf("fetch failed: %w", errOriginDown)
+		})
+	})
+
+	pipeline.RegisterFactory("Cache", func() ogcore.Node {
+		return ograph.NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			if err := ograph.LoadState[error](state, "Fetch_error"); !errors.Is(err, errOriginDown) {
+				return fmt.Errorf("unexpected error: %v", err)
+			}
+
+			state.Set("Source", "cache")
+			return nil
+		})
+	})
+
+	origin := ograph.NewElement("Origin").UseFactory("Origin")
+	cache := ograph.NewElement("Cache").UseFactory("Cache")
+
+	pipeline.Register(ograph.NewElement("Fetch").Apply(ogimpl.FallbackOp(origin, cache)))
+
+	// fallback cluster is serialized with its sub elements
+	graphData, err := pipeline.DumpGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pipeline.LoadGraph(graphData); err != nil {
+		t.Fatal(err)
+	}
+
+	state := ograph.NewState()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Error(err)
+	} else if source, _ := state.Get("Source"); source != "cache" {
+		t.Errorf("expect data from cache, got: %v", source)
+	}
+
+	// errors not matching FallbackOn are returned without fallback
+	pipeline = ograph.NewPipeline()
+
+	ran := false
+
+	pipeline.Register(ograph.NewElement("Fetch").
+		UseFactory(ogimpl.Fallback,
+			ograph.NewElement("Origin").UseFn(func() error { return errors.New("bad request") }),
+			ograph.NewElement("Cache").UseFn(func() error { ran = true; return nil })).
+		Params("FallbackOn", []error{errOriginDown}))
+
+	if err := pipeline.Run(context.TODO(), nil); err == nil || ran {
+		t.Errorf("expect error without fallback, err: %v, fallback ran: %v", err, ran)
+	}
+}
+
+type Backend struct {
+	ograph.BaseNode
+
+	Cost time.Duration
+}
+
+func (backend *Backend) Run(ctx context.Context, state ogcore.State) error {
+	state.Set("Backend", backend.Name())
+
+	select {
+	case <-time.After(backend.Cost):
+		state.Set("Result", "from "+backend.Name())
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestCluster_Hedge(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	primary := ograph.NewElement("Primary").UseNode(&Backend{Cost: time.Second})
+	replica := ograph.NewElement("Replica").UseNode(&Backend{Cost: 5 * time.Millisecond})
+
+	pipeline.Register(ograph.NewElement("Query").Apply(ogimpl.HedgeOp(10*time.Millisecond, primary, replica)))
+
+	state := ograph.NewState()
+	start := time.Now()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("slow primary is not hedged, elapsed: %s", elapsed)
+	}
+
+	// only writes of the winner are applied
+	if backend, _ := state.Get("Backend"); backend != "Replica" {
+		t.Errorf("expect writes of replica, got: %v", backend)
+	}
+
+	if result, _ := state.Get("Result"); result != "from Replica" {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	// fast primary wins without starting replica
+	pipeline = ograph.NewPipeline()
+
+	primary = ograph.NewElement("Primary").UseNode(&Backend{Cost: time.Millisecond})
+	replica = ograph.NewElement("Replica").UseNode(&Backend{Cost: time.Millisecond})
+
+	pipeline.Register(ograph.NewElement("Query").Apply(ogimpl.HedgeOp(time.Second, primary, replica)))
+
+	state = ograph.NewState()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	} else if backend, _ := state.Get("Backend"); backend != "Primary" {
+		t.Errorf("expect writes of primary, got: %v", backend)
+	}
+
+	// learned delays are scoped to pipelines without latency key
+	newLearning := func(primaryCost time.Duration, delay time.Duration) *ograph.Pipeline {
+		p := ograph.NewPipeline()
+
+		primary := ograph.NewElement("Primary").UseNode(&Backend{Cost: primaryCost})
+		replica := ograph.NewElement("Replica").UseNode(&Backend{Cost: 5 * time.Millisecond})
+
+		p.Register(ograph.NewElement("Query").Apply(ogimpl.HedgeOp(delay, primary, replica)).
+			Params("HedgePercentile", 50.0).Params("MinSamples", 1))
+		return p
+	}
+
+	// learns a delay of about 200ms
+	if err := newLearning(200*time.Millisecond, time.Second).Run(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	start = time.Now()
+
+	if err := newLearning(time.Second, 10*time.Millisecond).Run(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("delay is learned from another pipeline, elapsed: %s", elapsed)
+	}
+}
+
+func TestCluster_Quorum(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	replicas := []*ograph.Element{
+		ograph.NewElement("Replica1").UseNode(&Backend{Cost: time.Millisecond}),
+		ograph.NewElement("Replica2").UseNode(&Backend{Cost: 2 * time.Millisecond}),
+		ograph.NewElement("Replica3").UseNode(&Backend{Cost: time.Second}),
+	}
+
+	pipeline.Register(ograph.NewElement("Write").Apply(ogimpl.QuorumOp(2, replicas...)))
+
+	state := ograph.NewState()
+	start := time.Now()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("quorum cluster did not return early, elapsed: %s", elapsed)
+	}
+
+	outcomes := ograph.LoadState[[]ogimpl.QuorumOutcome](state, "Write_outcomes")
+
+	// Replica3 is canceled after decision, so it's not finished in outcomes
+	if len(outcomes) != 3 || !outcomes[0].Finished || outcomes[0].Err != nil || !outcomes[1].Finished ||
+		outcomes[1].Err != nil || outcomes[2].Finished || outcomes[2].Err != nil {
+
+		t.Errorf("unexpected outcomes: %+v", outcomes)
+	}
+
+	// fails as soon as quorum becomes impossible
+	pipeline = ograph.NewPipeline()
+
+	down := func() error { return errors.New("replica is down") }
+
+	pipeline.Register(ograph.NewElement("Write").
+		UseFactory(ogimpl.Quorum,
+			ograph.NewElement("Replica1").UseFn(down),
+			ograph.NewElement("Replica2").UseFn(down),
+			ograph.NewElement("Replica3").UseNode(&Backend{Cost: time.Second})))
+
+	start = time.Now()
+
+	err := pipeline.Run(context.TODO(), nil)
+
+	var quorumErr *ogimpl.QuorumError
+
+	if !errors.Is(err, ogimpl.ErrQuorumFailed) || !errors.As(err, &quorumErr) {
+		t.Fatalf("expect quorum error, got: %v", err)
+	}
+
+	if quorumErr.Required != 2 || quorumErr.Succeeded != 0 || quorumErr.Outcomes[2].Finished {
+		t.Errorf("unexpected quorum error: %+v", quorumErr)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("quorum cluster did not fail early, elapsed: %s", elapsed)
+	}
+
+	// without CancelRest, the rest run to the end and are awaited
+	pipeline = ograph.NewPipeline()
+
+	var slowDone atomic.Bool
+
+	pipeline.Register(ograph.NewElement("Write").
+		Apply(ogimpl.QuorumOp(2,
+			ograph.NewElement("Replica1").UseNode(&Backend{Cost: time.Millisecond}),
+			ograph.NewElement("Replica2").UseNode(&Backend{Cost: time.Millisecond}),
+			ograph.NewElement("Replica3").UseFn(func() error {
+				time.Sleep(50 * time.Millisecond)
+				slowDone.Store(true)
+				return nil
+			}))).
+		Params("CancelRest", false))
+
+	state = ograph.NewState()
+
+	if err := pipeline.Run(context.TODO(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slowDone.Load() {
+		t.Error("expect the rest awaited before cluster returns")
+	}
+
+	if outcomes := ograph.LoadState[[]ogimpl.QuorumOutcome](state, "Write_outcomes"); outcomes[2].Finished {
+		t.Errorf("unexpected outcomes: %+v", outcomes)
+	}
+}
+
+func TestCluster_Saga(t *testing.T) {
+	var booked []string
+	var cancelFailures int
+
+	step := func(name string, err error) *ograph.Element {
+		return ograph.NewElement(name).UseFn(func() error {
+			if err == nil {
+				booked = append(booked, name)
+			}
+
+			return err
+		})
+	}
+
+	cancel := func(name string) *ograph.Element {
+		return ograph.NewElement("Cancel" + name).UseFn(func() error {
+			if name == "Flight" && cancelFailures > 0 {
+				cancelFailures--
+				return errors.New("airline is busy")
+			}
+
+			booked = slices.DeleteFunc(booked, func(s string) bool { return s == name })
+			return nil
+		})
+	}
+
+	errNoCar := errors.New("no car available")
+
+	pipeline := ograph.NewPipeline()
+
+	pipeline.Register(ograph.NewElement("Booking").Apply(
+		ogimpl.SagaStepOp(step("Flight", nil), cancel("Flight")),
+		ogimpl.SagaStepOp(step("Hotel", nil), cancel("Hotel")),
+		ogimpl.SagaStepOp(step("Car", errNoCar), nil),
+	))
+
+	// compensation of flight fails once, it's retried by running again on the same state
+	cancelFailures = 1
+
+	state := ograph.NewState()
+
+	err := pipeline.Run(context.TODO(), state)
+	if !errors.Is(err, ogimpl.ErrCompensationFailed) {
+		t.Fatalf("expect compensation failed, got: %v", err)
+	}
+
+	if !errors.Is(err, errNoCar) {
+		t.Errorf("expect step error wrapped, got: %v", err)
+	}
+
+	progress := ograph.LoadState[ogimpl.SagaProgress](state, "Booking_saga")
+
+	if progress.Status != ogimpl.SagaCompensating || progress.FailedStep != "Car" ||
+		!slices.Equal(progress.Compensated, []string{"Hotel"}) {
+
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+
+	if !slices.Equal(booked, []string{"Flight"}) {
+		t.Errorf("expect hotel canceled, booked: %v", booked)
+	}
+
+	// progress restored from journal is generic json value
+	var generic map[string]any
+	raw, _ := json.Marshal(progress)
+	json.Unmarshal(raw, &generic)
+	state.Set("Booking_saga", generic)
+
+	err = pipeline.Run(context.TODO(), state)
+	if err == nil || errors.Is(err, ogimpl.ErrCompensationFailed) {
+		t.Fatalf("expect only step error, got: %v", err)
+	}
+
+	if len(booked) != 0 {
+		t.Errorf("expect all canceled, booked: %v", booked)
+	}
+
+	if progress := ograph.LoadState[ogimpl.SagaProgress](state, "Booking_saga"); progress.Status != ogimpl.SagaCompensated {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -247,3 +248,125 @@ func TestAdvance_Event(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestAdvance_Deterministic(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	// nodes and cluster members run one by one, in order generated from seed.
+	pipeline.Deterministic = true
+	pipeline.Seed = 2025
+
+	var trace []string
+
+	var members []*ograph.Element
+
+	for _, name := range []string{"A", "B", "C", "D"} {
+		members = append(members, ograph.NewElement(name).UseFn(func() error {
+			trace = append(trace, name)
+			return nil
+		}))
+	}
+
+	pipeline.Register(ograph.NewElement("Members").UseFactory(ogimpl.Parallel, members...))
+
+	if err := pipeline.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	}
+
+	replay := slices.Clone(trace)
+	trace = nil
+
+	if err := pipeline.Run(context.TODO(), nil); err != nil {
+		t.Error(err)
+	} else if !slices.Equal(trace, replay) {
+		t.Errorf("replay got %v, want %v", trace, replay)
+	} else {
+		fmt.Println(trace)
+	}
+}
+
+func TestAdvance_LifecycleEvent(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	var events []string
+	var runIDs []string
+
+	// framework emits pipeline.*, node.* events, details are carried by payload of *ograph.LifecycleEvent
+	ograph.SubscribePayload(pipeline, func(event string, state ogcore.State, e *ograph.LifecycleEvent) bool {
+		events = append(events, event+" "+e.Node)
+		runIDs = append(runIDs, e.RunID)
+
+		if e.Err != nil {
+			fmt.Printf("%s %s after %s, error: %v\n", event, e.Node, e.Duration, e.Err)
+		}
+		return true
+	}, eventd.On(`^(pipeline|node)\.`))
+
+	zhangSan := ograph.NewElement("ZhangSan").UseNode(&Loser{}).Apply(ogimpl.RetryOp(99))
+	flash := ograph.NewElement("Flash").UseNode(&Sloth{}).Apply(ogimpl.TimeoutOp(10 * time.Millisecond))
+
+	pipeline.Register(zhangSan).Register(flash, ograph.Rely(zhangSan))
+
+	if err := pipeline.Run(context.TODO(), nil); !errors.Is(err, ogimpl.ErrTimeout) {
+		t.Errorf("got err = %v, want %v", err, ogimpl.ErrTimeout)
+	}
+
+	if events[0] != "pipeline.start " || events[len(events)-1] != "pipeline.end " {
+		t.Errorf("got events %v, want started with pipeline.start and ended with pipeline.end", events)
+	}
+
+	for _, want := range []string{"node.retry ZhangSan", "node.end ZhangSan", "node.timeout Flash", "node.error Flash"} {
+		if !slices.Contains(events, want) {
+			t.Errorf("got events %v, want %s", events, want)
+		}
+	}
+
+	if runIDs[0] == "" || slices.ContainsFunc(runIDs, func(id string) bool { return id != runIDs[0] }) {
+		t.Errorf("got run IDs %v, want the same ID", runIDs)
+	}
+}
+
+type Alert struct {
+	Level string
+	Msg   string
+}
+
+type TAlertNode struct {
+	ograph.BaseEventNode
+}
+
+func (node *TAlertNode) Run(ctx context.Context, state ogcore.State) error {
+	node.EmitPayload("alert", state, Alert{Level: "warn", Msg: "disk is almost full"})
+	node.EmitPayload("alert", state, "not an Alert")
+	return nil
+}
+
+func TestAdvance_EventPayload(t *testing.T) {
+	pipeline := ograph.NewPipeline()
+
+	var alerts []Alert
+
+	// only events whose payload is Alert are received, events from sub pipeline are forwarded with payload.
+	ograph.SubscribePayload(pipeline, func(event string, state ogcore.State, alert Alert) bool {
+		fmt.Printf("get %s alert: %s\n", alert.Level, alert.Msg)
+		alerts = append(alerts, alert)
+		return true
+	}, eventd.On("alert"))
+
+	subPipeline := ograph.NewPipeline()
+	subPipeline.Register(ograph.NewElement("n").UseNode(&TAlertNode{}))
+
+	pipeline.Register(ograph.NewElement("sub").UseNode(subPipeline))
+
+	for i := 0; i < 2; i++ {
+		pipeline.ResetPool()
+
+		if err := pipeline.Run(context.Background(), nil); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if len(alerts) != 2 {
+		t.Errorf("got %d alerts, want 2", len(alerts))
+	}
+}
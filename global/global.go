@@ -1,5 +1,11 @@
 package global
 
-import "github.com/symphony09/ograph/ogcore"
+import (
+	"github.com/symphony09/eventd"
+	"github.com/symphony09/ograph/ogcore"
+)
 
 var Factories = ogcore.NewFactories()
+
+// EventBus is shared by the whole program, events which are not bound to a pipeline can be emitted on it.
+var EventBus = new(eventd.EventBus[ogcore.State])
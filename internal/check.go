@@ -1,15 +1,236 @@
 package internal
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var ErrDanglingEdge error = errors.New("found dangling edge")
+
+// CycleError lists every elementary cycle found in a graph,
+// each cycle is an ordered path which ends with its first vertex.
+type CycleError struct {
+	Cycles [][]string
+}
+
+func (err *CycleError) Error() string {
+	paths := make([]string, 0, len(err.Cycles))
+
+	for _, cycle := range err.Cycles {
+		paths = append(paths, strings.Join(cycle, " -> "))
+	}
+
+	return fmt.Sprintf("found cycle: %s", strings.Join(paths, "; "))
+}
+
+func FormatEdges(edges [][2]string) string {
+	ret := make([]string, 0, len(edges))
+
+	for _, edge := range edges {
+		ret = append(ret, edge[0]+" -> "+edge[1])
+	}
+
+	slices.Sort(ret)
+
+	return strings.Join(slices.Compact(ret), ", ")
+}
 
 func (graph *Graph[E]) Check() error {
-	_, left := graph.Steps()
+	if edges := graph.DanglingEdges(); len(edges) != 0 {
+		return fmt.Errorf("%w, edges: %s", ErrDanglingEdge, FormatEdges(edges))
+	}
+
+	if cycles := graph.FindCycles(); len(cycles) != 0 {
+		return &CycleError{Cycles: cycles}
+	}
+
+	return nil
+}
+
+// DanglingEdges returns edges whose endpoints are not vertices of the graph.
+func (graph *Graph[E]) DanglingEdges() [][2]string {
+	graph.Lock()
+	defer graph.Unlock()
+
+	dangling := make([][2]string, 0)
+
+	for edge := range graph.Edges {
+		if graph.Vertices[edge.From.Name] != edge.From || graph.Vertices[edge.To.Name] != edge.To {
+			dangling = append(dangling, [2]string{edge.From.Name, edge.To.Name})
+		}
+	}
+
+	return dangling
+}
+
+// MaxCycles is the max number of cycles returned by FindCycles, a dense graph can have exponentially many of them.
+const MaxCycles = 100
+
+// FindCycles returns each distinct elementary cycle of the graph, up to MaxCycles of them (johnson).
+// Every cycle starts from its smallest vertex name, so the result is stable between calls.
+func (graph *Graph[E]) FindCycles() [][]string {
+	graph.Lock()
+	defer graph.Unlock()
+
+	names := slices.Sorted(maps.Keys(graph.Vertices))
 
-	if len(left) != 0 {
-		return fmt.Errorf("found cycle between vertices: %v", left)
-	} else {
-		return nil
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
 	}
+
+	// next vertices which are still in graph, ordered by name
+	nextOf := func(v *GraphVertex[E]) []*GraphVertex[E] {
+		next := make([]*GraphVertex[E], 0, len(v.Next))
+
+		for _, n := range v.Next {
+			if graph.Vertices[n.Name] == n {
+				next = append(next, n)
+			}
+		}
+
+		slices.SortFunc(next, func(a, b *GraphVertex[E]) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		return next
+	}
+
+	components := graph.components(names, nextOf)
+
+	cycles := make([][]string, 0)
+
+	for i, start := range names {
+		if len(cycles) >= MaxCycles {
+			break
+		}
+
+		component := components[start]
+
+		// cycles through smaller vertices are already found, and only vertices
+		// in the same strongly connected component can be on the cycle.
+		inScope := func(v *GraphVertex[E]) bool {
+			return index[v.Name] >= i && components[v.Name] == component
+		}
+
+		var path []string
+		blocked := make(map[string]bool)
+		blockedBy := make(map[string]map[string]bool)
+
+		var unblock func(name string)
+		unblock = func(name string) {
+			blocked[name] = false
+
+			for w := range blockedBy[name] {
+				delete(blockedBy[name], w)
+
+				if blocked[w] {
+					unblock(w)
+				}
+			}
+		}
+
+		var visit func(v *GraphVertex[E]) bool
+		visit = func(v *GraphVertex[E]) bool {
+			found := false
+
+			path = append(path, v.Name)
+			blocked[v.Name] = true
+
+			for _, next := range nextOf(v) {
+				if !inScope(next) || len(cycles) >= MaxCycles {
+					continue
+				}
+
+				if next.Name == start {
+					cycles = append(cycles, append(slices.Clone(path), start))
+					found = true
+				} else if !blocked[next.Name] && visit(next) {
+					found = true
+				}
+			}
+
+			// vertex stays blocked until one of its next vertices is unblocked,
+			// so that paths which can't reach start are not searched again.
+			if found || len(cycles) >= MaxCycles {
+				unblock(v.Name)
+			} else {
+				for _, next := range nextOf(v) {
+					if inScope(next) {
+						if blockedBy[next.Name] == nil {
+							blockedBy[next.Name] = make(map[string]bool)
+						}
+
+						blockedBy[next.Name][v.Name] = true
+					}
+				}
+			}
+
+			path = path[:len(path)-1]
+
+			return found
+		}
+
+		visit(graph.Vertices[start])
+	}
+
+	return cycles
+}
+
+// components marks vertices with the id of their strongly connected component (tarjan).
+func (graph *Graph[E]) components(names []string, nextOf func(v *GraphVertex[E]) []*GraphVertex[E]) map[string]int {
+	var counter, componentId int
+
+	order := make(map[string]int, len(names))
+	low := make(map[string]int, len(names))
+	onStack := make(map[string]bool, len(names))
+	components := make(map[string]int, len(names))
+
+	var stack []string
+
+	var connect func(v *GraphVertex[E])
+	connect = func(v *GraphVertex[E]) {
+		counter++
+		order[v.Name], low[v.Name] = counter, counter
+
+		stack = append(stack, v.Name)
+		onStack[v.Name] = true
+
+		for _, next := range nextOf(v) {
+			if order[next.Name] == 0 {
+				connect(next)
+				low[v.Name] = min(low[v.Name], low[next.Name])
+			} else if onStack[next.Name] {
+				low[v.Name] = min(low[v.Name], order[next.Name])
+			}
+		}
+
+		if low[v.Name] == order[v.Name] {
+			componentId++
+
+			for {
+				name := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[name] = false
+				components[name] = componentId
+
+				if name == v.Name {
+					break
+				}
+			}
+		}
+	}
+
+	for _, name := range names {
+		if order[name] == 0 {
+			connect(graph.Vertices[name])
+		}
+	}
+
+	return components
 }
 
 func (graph *Graph[E]) Steps() ([][]string, []string) {
@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+// CronSchedule is parsed standard cron expression of 5 fields: minute, hour, day of month, month and day of week.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// if both day of month and day of week are restricted, a day matches either of them as cron does.
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses cron expression like "*/5 9-17 * * mon-fri", descriptors like @daily are supported.
+func ParseCron(spec string) (*CronSchedule, error) {
+	if expanded, ok := cronDescriptors[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w, expect 5 fields, got %d: %q", ErrInvalidCron, len(fields), spec)
+	}
+
+	schedule := &CronSchedule{
+		domStar: fields[2] == "*" || fields[2] == "?",
+		dowStar: fields[4] == "*" || fields[4] == "?",
+	}
+
+	var err error
+
+	for i, target := range []*uint64{&schedule.minute, &schedule.hour, &schedule.dom, &schedule.month, &schedule.dow} {
+		field := []cronField{minuteField, hourField, domField, monthField, dowField}[i]
+
+		if *target, err = field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("%w, %v: %q", ErrInvalidCron, err, spec)
+		}
+	}
+
+	// 7 is sunday too
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	return schedule, nil
+}
+
+func (field cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+
+		start, end, step := field.min, field.max, 1
+
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step %q", stepExpr)
+			}
+		}
+
+		if rangeExpr != "*" && rangeExpr != "?" {
+			lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+
+			var err error
+			if start, err = field.value(lowExpr); err != nil {
+				return 0, err
+			}
+
+			if isRange {
+				if end, err = field.value(highExpr); err != nil {
+					return 0, err
+				}
+			} else if !hasStep {
+				end = start
+			}
+
+			if start > end {
+				return 0, fmt.Errorf("bad range %q", rangeExpr)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func (field cronField) value(expr string) (int, error) {
+	if v, ok := field.names[strings.ToLower(expr)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(expr)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("bad value %q, expect %d-%d", expr, field.min, field.max)
+	}
+
+	return v, nil
+}
+
+// Next returns the first time matching schedule after t, zero time is returned if nothing matches in 5 years.
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+WRAP:
+	for t.Year() <= yearLimit {
+		for schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+
+			if t.Month() == time.January {
+				continue WRAP
+			}
+		}
+
+		for !schedule.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+
+			if t.Day() == 1 {
+				continue WRAP
+			}
+		}
+
+		for schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+
+			if t.Hour() == 0 {
+				continue WRAP
+			}
+		}
+
+		for schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+
+			if t.Minute() == 0 {
+				continue WRAP
+			}
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (schedule *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := schedule.dom&(1<<uint(t.Day())) != 0
+	dowMatch := schedule.dow&(1<<uint(t.Weekday())) != 0
+
+	if schedule.domStar || schedule.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
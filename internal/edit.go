@@ -0,0 +1,114 @@
+package internal
+
+import "slices"
+
+func (graph *Graph[E]) RemoveVertex(name string) bool {
+	graph.Lock()
+	defer graph.Unlock()
+
+	vertex := graph.Vertices[name]
+	if vertex == nil {
+		return false
+	}
+
+	for _, dep := range slices.Clone(vertex.Dependencies) {
+		graph.removeEdge(dep.Name, name)
+	}
+
+	for _, next := range slices.Clone(vertex.Next) {
+		graph.removeEdge(name, next.Name)
+	}
+
+	delete(graph.Vertices, name)
+	graph.optimized = false
+
+	return true
+}
+
+func (graph *Graph[E]) RemoveEdge(from, to string) bool {
+	graph.Lock()
+	defer graph.Unlock()
+
+	return graph.removeEdge(from, to)
+}
+
+// removeEdge removes edge from->to, it must be called with lock held.
+func (graph *Graph[E]) removeEdge(from, to string) bool {
+	fromVertex, toVertex := graph.Vertices[from], graph.Vertices[to]
+	if fromVertex == nil || toVertex == nil {
+		return false
+	}
+
+	edge := GraphEdge[E]{
+		From: fromVertex,
+		To:   toVertex,
+	}
+
+	if !graph.Edges[edge] {
+		return false
+	}
+
+	fromVertex.Next = slices.DeleteFunc(fromVertex.Next, func(v *GraphVertex[E]) bool {
+		return v == toVertex
+	})
+
+	toVertex.Dependencies = slices.DeleteFunc(toVertex.Dependencies, func(v *GraphVertex[E]) bool {
+		return v == fromVertex
+	})
+
+	delete(graph.Edges, edge)
+	graph.optimized = false
+
+	return true
+}
+
+// ReplaceVertex sets a new name and element for vertex, edges of vertex are kept.
+func (graph *Graph[E]) ReplaceVertex(name, newName string, elem E) bool {
+	graph.Lock()
+	defer graph.Unlock()
+
+	vertex := graph.Vertices[name]
+	if vertex == nil {
+		return false
+	}
+
+	if newName != name && graph.Vertices[newName] != nil {
+		return false
+	}
+
+	var priority int
+
+	if pe, ok := any(elem).(HasPriority); ok {
+		priority = pe.GetPriority()
+	}
+
+	delete(graph.Vertices, name)
+
+	vertex.Name = newName
+	vertex.Elem = elem
+	vertex.Priority = priority
+
+	graph.Vertices[newName] = vertex
+	graph.optimized = false
+
+	return true
+}
+
+// InsertVertex replaces edge from->to by from->name->to, vertex of name is added if not exist.
+func (graph *Graph[E]) InsertVertex(from, to, name string, elem E) bool {
+	graph.Lock()
+	defer graph.Unlock()
+
+	if !graph.removeEdge(from, to) {
+		return false
+	}
+
+	if graph.Vertices[name] == nil {
+		graph.AddVertex(name, elem)
+	}
+
+	graph.AddEdge(from, name)
+	graph.AddEdge(name, to)
+
+	return true
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+// StateExpr is a compiled expression whose identifiers are read from state.
+type StateExpr struct {
+	program     *vm.Program
+	identifiers []string
+}
+
+func CompileStateExpr(exprStr string) (*StateExpr, error) {
+	program, err := expr.Compile(exprStr)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &identifierVisitor{}
+	ast.Walk(&tree.Node, v)
+
+	return &StateExpr{program: program, identifiers: v.identifiers}, nil
+}
+
+// Run evaluates expression with values of its identifiers in state, vars take precedence over state.
+func (stateExpr *StateExpr) Run(state ogcore.State, vars map[string]any) (any, error) {
+	env := make(map[string]any, len(stateExpr.identifiers)+len(vars))
+
+	for _, identifier := range stateExpr.identifiers {
+		env[identifier], _ = state.Get(identifier)
+	}
+
+	for name, val := range vars {
+		env[name] = val
+	}
+
+	return expr.Run(stateExpr.program, env)
+}
+
+// RunBool is like Run, but the result should be bool.
+func (stateExpr *StateExpr) RunBool(state ogcore.State, vars map[string]any) (bool, error) {
+	output, err := stateExpr.Run(state, vars)
+	if err != nil {
+		return false, err
+	}
+
+	if ret, ok := output.(bool); ok {
+		return ret, nil
+	}
+
+	return false, fmt.Errorf("unknown result: %v", output)
+}
+
+type identifierVisitor struct {
+	identifiers []string
+}
+
+func (v *identifierVisitor) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.IdentifierNode); ok {
+		v.identifiers = append(v.identifiers, n.Value)
+	}
+}
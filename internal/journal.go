@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	RecordRunStart  = "run_start"
+	RecordRunResume = "run_resume"
+	RecordRunEnd    = "run_end"
+	RecordNodeStart = "node_start"
+	RecordNodeEnd   = "node_end"
+	RecordSet       = "set"
+	RecordSetBatch  = "set_batch"
+)
+
+var ErrJournalClosed = errors.New("journal is closed")
+
+// JournalRecord is a line of journal file.
+type JournalRecord struct {
+	Type     string          `json:"type"`
+	Run      string          `json:"run"`
+	Time     time.Time       `json:"time"`
+	Pipeline string          `json:"pipeline,omitempty"`
+	Node     string          `json:"node,omitempty"`
+	Key      string          `json:"key,omitempty"`
+	Kind     string          `json:"kind,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Err      string          `json:"err,omitempty"`
+}
+
+// JournalRun is a run replayed from journal.
+type JournalRun struct {
+	ID        string
+	Pipeline  string
+	StartTime time.Time
+
+	// Completed are nodes which succeeded, Running are nodes which started but not completed.
+	Completed []string
+	Running   []string
+
+	// State holds the latest records of state keys.
+	State map[string]JournalRecord
+
+	ended bool
+}
+
+// JournalFile is an append-only file of journal records, one json record per line.
+type JournalFile struct {
+	path string
+	file *os.File
+	sync bool
+
+	sync.Mutex
+}
+
+func OpenJournalFile(path string, syncWrites bool) (*JournalFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JournalFile{path: path, file: file, sync: syncWrites}, nil
+}
+
+func (jf *JournalFile) Append(records ...JournalRecord) error {
+	var buf []byte
+
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		buf = append(append(buf, line...), '\n')
+	}
+
+	jf.Lock()
+	defer jf.Unlock()
+
+	if jf.file == nil {
+		return ErrJournalClosed
+	}
+
+	if _, err := jf.file.Write(buf); err != nil {
+		return err
+	}
+
+	if jf.sync {
+		return jf.file.Sync()
+	}
+
+	return nil
+}
+
+// Replay reads records and returns runs in order of start, a broken line left by crash is skipped.
+func (jf *JournalFile) Replay() ([]*JournalRun, error) {
+	jf.Lock()
+	defer jf.Unlock()
+
+	records, err := jf.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return replay(records), nil
+}
+
+// Compact rewrites file with records of runs which are not ended.
+func (jf *JournalFile) Compact() error {
+	jf.Lock()
+	defer jf.Unlock()
+
+	if jf.file == nil {
+		return ErrJournalClosed
+	}
+
+	records, err := jf.read()
+	if err != nil {
+		return err
+	}
+
+	ended := make(map[string]bool)
+
+	for _, run := range replay(records) {
+		ended[run.ID] = run.ended
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(jf.path), filepath.Base(jf.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+
+	for _, record := range records {
+		if ended[record.Run] {
+			continue
+		}
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), jf.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(jf.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	jf.file.Close()
+	jf.file = file
+
+	return nil
+}
+
+func (jf *JournalFile) Close() error {
+	jf.Lock()
+	defer jf.Unlock()
+
+	if jf.file == nil {
+		return nil
+	}
+
+	err := jf.file.Close()
+	jf.file = nil
+
+	return err
+}
+
+func (jf *JournalFile) read() ([]JournalRecord, error) {
+	file, err := os.Open(jf.path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var records []JournalRecord
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+
+	for scanner.Scan() {
+		var record JournalRecord
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+func replay(records []JournalRecord) []*JournalRun {
+	var runs []*JournalRun
+
+	runMap := make(map[string]*JournalRun)
+
+	for _, record := range records {
+		run := runMap[record.Run]
+
+		if run == nil {
+			if record.Type != RecordRunStart {
+				continue
+			}
+
+			run = &JournalRun{
+				ID:        record.Run,
+				Pipeline:  record.Pipeline,
+				StartTime: record.Time,
+				State:     make(map[string]JournalRecord),
+			}
+
+			runMap[record.Run] = run
+			runs = append(runs, run)
+		}
+
+		switch record.Type {
+		case RecordNodeStart:
+			if !slices.Contains(run.Running, record.Node) {
+				run.Running = append(run.Running, record.Node)
+			}
+		case RecordNodeEnd:
+			run.Running = slices.DeleteFunc(run.Running, func(node string) bool {
+				return node == record.Node
+			})
+
+			if record.Err == "" && !slices.Contains(run.Completed, record.Node) {
+				run.Completed = append(run.Completed, record.Node)
+			}
+		case RecordSet:
+			run.State[record.Key] = record
+		case RecordSetBatch:
+			var batch []JournalRecord
+
+			if err := json.Unmarshal(record.Value, &batch); err == nil {
+				for _, set := range batch {
+					run.State[set.Key] = set
+				}
+			}
+		case RecordRunResume:
+			// nodes which were running when the run crashed will be run again
+			run.Running = nil
+		case RecordRunEnd:
+			run.ended = true
+		}
+	}
+
+	return runs
+}
+
+// Ended reports whether the run is ended, no matter it succeeded or not.
+func (run *JournalRun) Ended() bool {
+	return run.ended
+}
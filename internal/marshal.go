@@ -5,6 +5,15 @@ type GraphMarshaler[E any] struct {
 	Edges    [][2]string  `json:"Edges,omitempty"`
 
 	SubGraphs map[string]*GraphMarshaler[E] `json:"SubGraphs,omitempty"`
+
+	Templates map[string]*GraphMarshaler[E] `json:"Templates,omitempty"`
+	Instances []TemplateRef                 `json:"Instances,omitempty"`
+}
+
+type TemplateRef struct {
+	Template string
+	Prefix   string         `json:"Prefix,omitempty"`
+	Params   map[string]any `json:"Params,omitempty"`
 }
 
 func (marshaler GraphMarshaler[E]) GenerateGraph() *Graph[E] {
@@ -32,6 +32,10 @@ func (pool *WorkerPool) Reset() {
 	pool.lower = *new(sync.Pool)
 }
 
+func (pool *WorkerPool) CacheSize() int {
+	return pool.cache.MaxSize
+}
+
 type WorkerCache struct {
 	MaxSize int
 
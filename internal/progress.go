@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ProgressPending = "pending"
+	ProgressQueued  = "queued"
+	ProgressRunning = "running"
+	ProgressDone    = "done"
+	ProgressFailed  = "failed"
+)
+
+// VertexProgress is status of a vertex in a run, Elapsed is the running time so far.
+type VertexProgress struct {
+	Status  string
+	Elapsed time.Duration
+
+	startTime time.Time
+	endTime   time.Time
+}
+
+type RunSnapshot struct {
+	Elapsed  time.Duration
+	Vertices map[string]VertexProgress
+
+	Completed int
+	Total     int
+	Percent   float64
+}
+
+// RunProgress is reported when a vertex is completed.
+type RunProgress struct {
+	Node   string
+	Status string
+	Err    error
+
+	Completed int
+	Total     int
+	Percent   float64
+	Elapsed   time.Duration
+}
+
+// Progress records status of vertices in a run, it can be read while the run is going on.
+// Every vertex counts, so vertices zipped into a serial group move the progress one by one.
+type Progress struct {
+	startTime time.Time
+	vertices  map[string]*VertexProgress
+	completed int
+
+	callback func(progress RunProgress)
+
+	sync.Mutex
+}
+
+func (progress *Progress) Queue(names ...string) {
+	if progress == nil {
+		return
+	}
+
+	progress.Lock()
+	defer progress.Unlock()
+
+	for _, name := range names {
+		if v := progress.vertices[name]; v != nil && v.Status == ProgressPending {
+			v.Status = ProgressQueued
+		}
+	}
+}
+
+func (progress *Progress) Start(name string) {
+	if progress == nil {
+		return
+	}
+
+	progress.Lock()
+	defer progress.Unlock()
+
+	if v := progress.vertices[name]; v != nil {
+		v.Status = ProgressRunning
+		v.startTime = time.Now()
+	}
+}
+
+func (progress *Progress) End(name string, err error) {
+	if progress == nil {
+		return
+	}
+
+	progress.Lock()
+
+	v := progress.vertices[name]
+	if v == nil {
+		progress.Unlock()
+		return
+	}
+
+	if err != nil {
+		v.Status = ProgressFailed
+	} else {
+		v.Status = ProgressDone
+	}
+
+	v.endTime = time.Now()
+	progress.completed++
+
+	event := RunProgress{
+		Node:      name,
+		Status:    v.Status,
+		Err:       err,
+		Completed: progress.completed,
+		Total:     len(progress.vertices),
+		Percent:   percent(progress.completed, len(progress.vertices)),
+		Elapsed:   time.Since(progress.startTime),
+	}
+
+	callback := progress.callback
+
+	progress.Unlock()
+
+	if callback != nil {
+		callback(event)
+	}
+}
+
+func (progress *Progress) Snapshot() RunSnapshot {
+	progress.Lock()
+	defer progress.Unlock()
+
+	now := time.Now()
+
+	snapshot := RunSnapshot{
+		Elapsed:   now.Sub(progress.startTime),
+		Vertices:  make(map[string]VertexProgress, len(progress.vertices)),
+		Completed: progress.completed,
+		Total:     len(progress.vertices),
+		Percent:   percent(progress.completed, len(progress.vertices)),
+	}
+
+	for name, v := range progress.vertices {
+		vp := *v
+
+		if !v.endTime.IsZero() {
+			vp.Elapsed = v.endTime.Sub(v.startTime)
+		} else if !v.startTime.IsZero() {
+			vp.Elapsed = now.Sub(v.startTime)
+		}
+
+		snapshot.Vertices[name] = vp
+	}
+
+	return snapshot
+}
+
+func percent(completed, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+
+	return float64(completed) * 100 / float64(total)
+}
+
+func NewProgress(names []string, callback func(progress RunProgress)) *Progress {
+	progress := &Progress{
+		startTime: time.Now(),
+		vertices:  make(map[string]*VertexProgress, len(names)),
+		callback:  callback,
+	}
+
+	for _, name := range names {
+		progress.vertices[name] = &VertexProgress{Status: ProgressPending}
+	}
+
+	return progress
+}
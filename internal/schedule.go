@@ -1,11 +1,18 @@
 package internal
 
 import (
+	"cmp"
+	"errors"
 	"iter"
+	"math/rand/v2"
 	"runtime"
+	"slices"
+	"strings"
 )
 
-func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], parallelismLimit int) (todo <-chan []*GraphVertex[E], done chan<- []*GraphVertex[E]) {
+var ErrUnreachable = errors.New("some nodes cannot be run, please check whether there is a circular dependency")
+
+func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], hook InterruptHook, parallelismLimit int) (todo <-chan []*GraphVertex[E], done chan<- []*GraphVertex[E]) {
 	scheduleChanSize := 1 + graph.ScheduleNum/2
 
 	if parallelismLimit <= 0 {
@@ -21,19 +28,10 @@ func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], parallelismLimit
 	go func() {
 		defer graph.Unlock()
 
-		var nextInterrupt func() (string, bool)
-		var stopInterrupt func()
-		var interruptAt string
-		var doInterrupt bool
-
-		if interrupts != nil {
-			nextInterrupt, stopInterrupt = iter.Pull(interrupts)
-			defer stopInterrupt()
-
-			interruptAt, doInterrupt = nextInterrupt()
-		}
+		interrupter := newInterrupter(interrupts, hook, graph.snapshot)
+		defer interrupter.stop()
 
-		enableSerialGroup := interrupts == nil
+		enableSerialGroup := interrupts == nil && hook == nil
 
 		if !graph.optimized {
 			graph.Optimize()
@@ -52,9 +50,7 @@ func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], parallelismLimit
 			}
 
 			for _, v := range group {
-				if doInterrupt && (interruptAt == vertex.Name+":start" || interruptAt == "*:start" || interruptAt == "*") {
-					interruptAt, doInterrupt = nextInterrupt()
-				}
+				interrupter.check(vertex.Name, "start")
 
 				v.Status = StatusDoing
 			}
@@ -69,9 +65,7 @@ func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], parallelismLimit
 			}
 
 			for _, v := range group {
-				if doInterrupt && (interruptAt == v.Name+":end" || interruptAt == "*:end" || interruptAt == "*") {
-					interruptAt, doInterrupt = nextInterrupt()
-				}
+				interrupter.check(v.Name, "end")
 
 				v.Status = StatusDone
 
@@ -88,9 +82,7 @@ func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], parallelismLimit
 				}
 
 				for _, v := range group {
-					if doInterrupt && (interruptAt == v.Name+":start" || interruptAt == "*:start" || interruptAt == "*") {
-						interruptAt, doInterrupt = nextInterrupt()
-					}
+					interrupter.check(v.Name, "start")
 
 					v.Status = StatusDoing
 				}
@@ -113,6 +105,143 @@ func (graph *Graph[E]) Scheduling(interrupts iter.Seq[string], parallelismLimit
 	return todoCh, doneCh
 }
 
+// ScheduleInOrder runs ready vertices one by one in a reproducible order, ordered by priority then name.
+// If seed is not 0, vertex to run is picked from ready ones by a random generator seeded with it.
+func (graph *Graph[E]) ScheduleInOrder(interrupts iter.Seq[string], hook InterruptHook, seed int64, run func(v *GraphVertex[E]) error) error {
+	graph.Lock()
+	defer graph.Unlock()
+
+	graph.reset()
+
+	if !graph.optimized {
+		graph.Optimize()
+	}
+
+	interrupter := newInterrupter(interrupts, hook, graph.snapshot)
+	defer interrupter.stop()
+
+	var rng *rand.Rand
+	if seed != 0 {
+		rng = rand.New(rand.NewPCG(uint64(seed), 0))
+	}
+
+	ready := slices.Clone(graph.Heads)
+
+	for len(ready) > 0 {
+		slices.SortFunc(ready, func(v1, v2 *GraphVertex[E]) int {
+			if c := cmp.Compare(v2.Priority, v1.Priority); c != 0 {
+				return c
+			}
+			return strings.Compare(v1.Name, v2.Name)
+		})
+
+		var i int
+		if rng != nil {
+			i = rng.IntN(len(ready))
+		}
+
+		v := ready[i]
+		ready = slices.Delete(ready, i, i+1)
+
+		interrupter.check(v.Name, "start")
+		v.Status = StatusDoing
+
+		if err := run(v); err != nil {
+			return err
+		}
+
+		v.Status = StatusDone
+		interrupter.check(v.Name, "end")
+
+		for _, next := range v.Next {
+			next.Wait--
+
+			if next.Wait == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	for _, v := range graph.VertexSlice {
+		if v.Status != StatusDone {
+			return ErrUnreachable
+		}
+	}
+
+	return nil
+}
+
+const (
+	VertexTodo    = "todo"
+	VertexReady   = "ready"
+	VertexRunning = "running"
+	VertexDone    = "done"
+)
+
+// InterruptHook is called by scheduler on start and end of each vertex, scheduling is blocked until it returns.
+// snapshot returns status of all vertices, it should only be called during the hook call.
+type InterruptHook func(name string, event string, snapshot func() map[string]string)
+
+// interrupter blocks scheduling until interrupts yield the next point,
+// points are like "node:start", "node:end", "*:start", "*:end" and "*".
+type interrupter struct {
+	next     func() (string, bool)
+	stopPull func()
+
+	at string
+	do bool
+
+	hook     InterruptHook
+	snapshot func() map[string]string
+}
+
+func newInterrupter(interrupts iter.Seq[string], hook InterruptHook, snapshot func() map[string]string) *interrupter {
+	it := &interrupter{hook: hook, snapshot: snapshot}
+
+	if interrupts != nil {
+		it.next, it.stopPull = iter.Pull(interrupts)
+		it.at, it.do = it.next()
+	}
+
+	return it
+}
+
+func (it *interrupter) check(name string, event string) {
+	if it.hook != nil {
+		it.hook(name, event, it.snapshot)
+	}
+
+	if it.do && (it.at == name+":"+event || it.at == "*:"+event || it.at == "*") {
+		it.at, it.do = it.next()
+	}
+}
+
+func (it *interrupter) stop() {
+	if it.stopPull != nil {
+		it.stopPull()
+	}
+}
+
+// snapshot must be called by scheduler, which holds the graph lock.
+func (graph *Graph[E]) snapshot() map[string]string {
+	statuses := make(map[string]string, len(graph.Vertices))
+
+	for name, v := range graph.Vertices {
+		switch {
+		case v.Status == StatusDone:
+			statuses[name] = VertexDone
+		case v.Status == StatusDoing:
+			statuses[name] = VertexRunning
+		case v.Wait == 0:
+			statuses[name] = VertexReady
+		default:
+			statuses[name] = VertexTodo
+		}
+	}
+
+	return statuses
+}
+
 func (graph *Graph[E]) reset() {
 	if graph.VertexSlice != nil {
 		for _, v := range graph.VertexSlice {
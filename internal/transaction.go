@@ -2,11 +2,16 @@ package internal
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/symphony09/ograph/ogcore"
 )
 
+var ErrCommitFailed = errors.New("transaction commit failed")
+var ErrRollbackFailed = errors.New("transaction rollback failed")
+
 const (
 	statusUnCommitted = 0
 	statusPreCommit   = 1
@@ -15,17 +20,34 @@ const (
 )
 
 type TransactionManager struct {
-	transactions map[ogcore.Transactional]int
+	transactions map[*Transaction]int
+
+	preCommitted []*Transaction
+
+	// afterEnd is called after transactions are committed or rolled back.
+	afterEnd []func()
 
-	preCommitted []ogcore.Transactional
+	// scope is the current run of manager.
+	scope *TransactionScope
 
 	sync.Mutex
 }
 
+// TransactionScope is a run of manager, nested pipelines in the run join their transactions into it.
+// A nested pipeline may finish after the run has ended, e.g. it's left running by a cluster, then its
+// transactions are committed or rolled back at once as the run was.
+type TransactionScope struct {
+	manager *TransactionManager
+
+	// ended is set when transactions of the run are taken to commit or roll back, guarded by lock of manager.
+	ended      bool
+	rolledBack bool
+}
+
 type Transaction struct {
 	Manager *TransactionManager
 
-	Node ogcore.Transactional
+	Node ogcore.Node
 }
 
 func (tx *Transaction) Run(ctx context.Context, state ogcore.State) error {
@@ -34,14 +56,54 @@ func (tx *Transaction) Run(ctx context.Context, state ogcore.State) error {
 	return tx.Node.Run(ctx, state)
 }
 
-func (manager *TransactionManager) Manage(txNode ogcore.Transactional) *Transaction {
-	manager.transactions[txNode] = statusUnCommitted
+func (tx *Transaction) name() string {
+	if nameable, ok := tx.Node.(ogcore.Nameable); ok {
+		return nameable.Name()
+	}
+
+	return "unknown"
+}
+
+func (tx *Transaction) commit(ctx context.Context) error {
+	switch txNode := tx.Node.(type) {
+	case ogcore.ContextTransactional:
+		return txNode.CommitContext(ctx)
+	case ogcore.Transactional:
+		txNode.Commit()
+	}
+
+	return nil
+}
+
+func (tx *Transaction) rollback(ctx context.Context) error {
+	switch txNode := tx.Node.(type) {
+	case ogcore.ContextTransactional:
+		return txNode.RollbackContext(ctx)
+	case ogcore.Transactional:
+		txNode.Rollback()
+	}
+
+	return nil
+}
 
+// IsTransactional reports whether node implements ogcore.Transactional or ogcore.ContextTransactional.
+func IsTransactional(node ogcore.Node) bool {
+	switch node.(type) {
+	case ogcore.ContextTransactional, ogcore.Transactional:
+		return true
+	}
+
+	return false
+}
+
+func (manager *TransactionManager) Manage(txNode ogcore.Node) *Transaction {
 	transaction := &Transaction{
 		Manager: manager,
 		Node:    txNode,
 	}
 
+	manager.transactions[transaction] = statusUnCommitted
+
 	return transaction
 }
 
@@ -49,43 +111,161 @@ func (manager *TransactionManager) PreCommit(tx *Transaction) {
 	manager.Lock()
 	defer manager.Unlock()
 
-	txNode := tx.Node
-
-	if manager.transactions[txNode] == statusPreCommit {
+	if manager.transactions[tx] == statusPreCommit {
 		return
 	}
 
-	manager.preCommitted = append(manager.preCommitted, txNode)
-	manager.transactions[txNode] = statusPreCommit
+	manager.preCommitted = append(manager.preCommitted, tx)
+	manager.transactions[tx] = statusPreCommit
 }
 
-func (manager *TransactionManager) CommitAll() {
+// Begin starts a new run of manager, transactions pre-committed from now on belong to the returned scope.
+func (manager *TransactionManager) Begin() *TransactionScope {
 	manager.Lock()
 	defer manager.Unlock()
 
-	for _, txNode := range manager.preCommitted {
-		txNode.Commit()
-		manager.transactions[txNode] = statusCommitted
+	manager.scope = &TransactionScope{manager: manager}
+
+	return manager.scope
+}
+
+// AfterEnd adds fn to be called after transactions of the run are committed or rolled back,
+// fn is called at once if the run has ended.
+func (scope *TransactionScope) AfterEnd(fn func()) {
+	scope.manager.Lock()
+
+	if !scope.ended {
+		scope.manager.afterEnd = append(scope.manager.afterEnd, fn)
+		scope.manager.Unlock()
+
+		return
+	}
+
+	scope.manager.Unlock()
+
+	fn()
+}
+
+// Adopt moves pre-committed transactions of child into the run, so that they are committed or rolled back with
+// transactions of the run, it reports whether any transaction is adopted. If the run has ended, they are committed
+// or rolled back at once as the run was.
+func (scope *TransactionScope) Adopt(ctx context.Context, child *TransactionManager) (bool, error) {
+	scope.manager.Lock()
+
+	if !scope.ended {
+		defer scope.manager.Unlock()
+
+		preCommitted, afterEnd := child.take(false, false)
+
+		scope.manager.preCommitted = append(scope.manager.preCommitted, preCommitted...)
+		scope.manager.afterEnd = append(scope.manager.afterEnd, afterEnd...)
+
+		return len(preCommitted) > 0, nil
+	}
+
+	rolledBack := scope.rolledBack
+	scope.manager.Unlock()
+
+	if rolledBack {
+		return false, child.RollbackAll(ctx)
 	}
 
-	manager.preCommitted = manager.preCommitted[:0]
+	return false, child.CommitAll(ctx)
 }
 
-func (manager *TransactionManager) RollbackAll() {
+// take returns pre-committed transactions and callbacks, and resets them. If end is set, the current run is ended.
+func (manager *TransactionManager) take(end bool, rollback bool) ([]*Transaction, []func()) {
 	manager.Lock()
 	defer manager.Unlock()
 
-	for i := len(manager.preCommitted) - 1; i >= 0; i-- {
-		txNode := manager.preCommitted[i]
-		txNode.Rollback()
-		manager.transactions[txNode] = statusRollback
+	if end && manager.scope != nil {
+		manager.scope.ended, manager.scope.rolledBack = true, rollback
 	}
 
-	manager.preCommitted = manager.preCommitted[:0]
+	preCommitted, afterEnd := manager.preCommitted, manager.afterEnd
+	manager.preCommitted, manager.afterEnd = nil, nil
+
+	return preCommitted, afterEnd
+}
+
+// setStatus sets status of tx in its own manager, which may differ from the manager committing it.
+func (tx *Transaction) setStatus(status int) {
+	tx.Manager.Lock()
+	defer tx.Manager.Unlock()
+
+	tx.Manager.transactions[tx] = status
+}
+
+// CommitAll commits pre-committed transactions in order, if one fails, it and the rest are rolled back in reverse order.
+func (manager *TransactionManager) CommitAll(ctx context.Context) error {
+	preCommitted, afterEnd := manager.take(true, false)
+
+	defer func() {
+		for _, fn := range afterEnd {
+			fn()
+		}
+	}()
+
+	for i, tx := range preCommitted {
+		if err := tx.commit(ctx); err != nil {
+			errs := []error{fmt.Errorf("%w, node: %s, err: %w", ErrCommitFailed, tx.name(), err)}
+
+			if err := manager.rollback(ctx, preCommitted[i:]); err != nil {
+				errs = append(errs, err)
+			}
+
+			return errors.Join(errs...)
+		}
+
+		tx.setStatus(statusCommitted)
+	}
+
+	return nil
+}
+
+// RollbackAll rolls back pre-committed transactions in reverse order, all of them are tried even if some fail.
+func (manager *TransactionManager) RollbackAll(ctx context.Context) error {
+	preCommitted, afterEnd := manager.take(true, true)
+
+	defer func() {
+		for _, fn := range afterEnd {
+			fn()
+		}
+	}()
+
+	return manager.rollback(ctx, preCommitted)
+}
+
+func (manager *TransactionManager) rollback(ctx context.Context, transactions []*Transaction) error {
+	var errs []error
+
+	for i := len(transactions) - 1; i >= 0; i-- {
+		tx := transactions[i]
+
+		if err := tx.rollback(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%w, node: %s, err: %w", ErrRollbackFailed, tx.name(), err))
+		}
+
+		tx.setStatus(statusRollback)
+	}
+
+	return errors.Join(errs...)
+}
+
+type txScopeKey struct{}
+
+// WithTransactionScope returns ctx carrying scope as transaction scope of nested pipelines.
+func WithTransactionScope(ctx context.Context, scope *TransactionScope) context.Context {
+	return context.WithValue(ctx, txScopeKey{}, scope)
+}
+
+func TransactionScopeFrom(ctx context.Context) *TransactionScope {
+	scope, _ := ctx.Value(txScopeKey{}).(*TransactionScope)
+	return scope
 }
 
 func NewTransactionManager() *TransactionManager {
 	return &TransactionManager{
-		transactions: make(map[ogcore.Transactional]int),
+		transactions: make(map[*Transaction]int),
 	}
 }
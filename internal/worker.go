@@ -14,6 +14,17 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	EventPipelineStart = "pipeline.start"
+	EventPipelineEnd   = "pipeline.end"
+	EventNodeStart     = "node.start"
+	EventNodeEnd       = "node.end"
+	EventNodeError     = "node.error"
+)
+
+// Listener is notified of lifecycle events of a run, name is empty for pipeline events.
+type Listener func(event string, name string, elapsed time.Duration, err error)
+
 type Worker struct {
 	graph *Graph[ogcore.Node]
 
@@ -24,30 +35,66 @@ type WorkParams struct {
 	GorLimit   int
 	Tracker    *ogcore.Tracker
 	Interrupts iter.Seq[string]
+	Hook       InterruptHook
+	Progress   *Progress
+	Listener   Listener
+
+	// Completed vertices are skipped, they are completed in the run which is resumed.
+	Completed map[string]bool
 
 	Pause        bool
 	ContinueCond *sync.Cond
+
+	Deterministic bool
+	Seed          int64
+
+	// Adopted is set by Work if transactions of the run are adopted by the outer run,
+	// nodes of the worker are used when transactions of the outer run end.
+	Adopted bool
 }
 
 func (worker *Worker) Work(ctx context.Context, state ogcore.State, params *WorkParams) (err error) {
 	var completedNum atomic.Uint32
 
+	startTime := time.Now()
+	params.notify(EventPipelineStart, "", 0, nil)
+
+	// transactions of nested pipeline are committed or rolled back with the outer run
+	outerTxScope := TransactionScopeFrom(ctx)
+	txCtx := context.WithoutCancel(ctx)
+	ctx = WithTransactionScope(ctx, worker.txManager.Begin())
+
 	defer func() {
 		if err == nil && completedNum.Load() < uint32(worker.graph.ScheduleNum) {
-			err = errors.New("some nodes cannot be run, please check whether there is a circular dependency")
+			err = ErrUnreachable
 		}
 
 		if err != nil {
-			worker.txManager.RollbackAll()
+			if txErr := worker.txManager.RollbackAll(txCtx); txErr != nil {
+				err = errors.Join(err, txErr)
+			}
+		} else if outerTxScope != nil {
+			params.Adopted, err = outerTxScope.Adopt(txCtx, worker.txManager)
 		} else {
-			worker.txManager.CommitAll()
+			err = worker.txManager.CommitAll(txCtx)
 		}
+
+		params.notify(EventPipelineEnd, "", time.Since(startTime), err)
 	}()
 
-	tracker := params.Tracker
+	if params.Deterministic {
+		// unfinished vertices are reported by ScheduleInOrder itself
+		completedNum.Store(uint32(worker.graph.ScheduleNum))
 
-	// opt for graph that can be fully serialized
-	if worker.graph.ScheduleNum == 1 {
+		return worker.graph.ScheduleInOrder(params.Interrupts, params.Hook, params.Seed, func(work *GraphVertex[ogcore.Node]) error {
+			params.Progress.Queue(work.Name)
+
+			return runVertex(ctx, state, work, params)
+		})
+	}
+
+	// opt for graph that can be fully serialized, scheduler is needed to interrupt
+	if worker.graph.ScheduleNum == 1 && params.Interrupts == nil && params.Hook == nil {
 		if len(worker.graph.Heads) == 0 {
 			return nil
 		}
@@ -61,101 +108,38 @@ func (worker *Worker) Work(ctx context.Context, state ogcore.State, params *Work
 			works = append(works, headNode)
 		}
 
-		var currentWorkName string
-
-		defer func() {
-			if info := recover(); info != nil {
-				err = fmt.Errorf("worker panic on %s, info: %v", currentWorkName, info)
-			}
-
-			completedNum.Add(1)
-		}()
+		defer completedNum.Add(1)
 
 		for _, work := range works {
-			if params.ContinueCond != nil {
-				waitContinue(params)
-			}
-
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-
-			currentWorkName = work.Name
-			node := work.Elem
-
-			if tracker != nil {
-				tracker.Record(currentWorkName, "ready", time.Now())
-			}
-
-			if tracker != nil {
-				tracker.Record(currentWorkName, "start", time.Now())
-			}
-
-			if node != nil {
-				if err := node.Run(ctx, state); err != nil {
-					return fmt.Errorf("%s failed, error: %w", work.Name, err)
-				}
-			}
-
-			if tracker != nil {
-				tracker.Record(currentWorkName, "end", time.Now())
-			}
+			params.Progress.Queue(work.Name)
+		}
 
-			if tracker != nil {
-				tracker.Record(currentWorkName, "complete", time.Now())
+		for _, work := range works {
+			if err := runVertex(ctx, state, work, params); err != nil {
+				return err
 			}
 		}
+
 		return nil
 	}
 
 	// schedule as normal
-	todoCh, doneCh := worker.graph.Scheduling(params.Interrupts, params.GorLimit)
+	todoCh, doneCh := worker.graph.Scheduling(params.Interrupts, params.Hook, params.GorLimit)
 	defer close(doneCh)
 
-	doWorks := func(works []*GraphVertex[ogcore.Node]) (err error) {
-		var currentWorkName string
-
+	doWorks := func(works []*GraphVertex[ogcore.Node]) error {
 		defer func() {
-			if info := recover(); info != nil {
-				err = fmt.Errorf("worker panic on %s, info: %v", currentWorkName, info)
-			}
-
 			doneCh <- works
 			completedNum.Add(1)
 		}()
 
 		for _, work := range works {
-			if params.ContinueCond != nil {
-				waitContinue(params)
-			}
-
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-
-			currentWorkName = work.Name
-			node := work.Elem
-
-			if tracker != nil {
-				tracker.Record(currentWorkName, "ready", time.Now())
-			}
-
-			if tracker != nil {
-				tracker.Record(currentWorkName, "start", time.Now())
-			}
-
-			if node != nil {
-				if err := node.Run(ctx, state); err != nil {
-					return fmt.Errorf("%s failed, error: %w", work.Name, err)
-				}
-			}
-
-			if tracker != nil {
-				tracker.Record(currentWorkName, "end", time.Now())
-			}
+			params.Progress.Queue(work.Name)
+		}
 
-			if tracker != nil {
-				tracker.Record(currentWorkName, "complete", time.Now())
+		for _, work := range works {
+			if err := runVertex(ctx, state, work, params); err != nil {
+				return err
 			}
 		}
 
@@ -208,6 +192,62 @@ func (worker *Worker) Work(ctx context.Context, state ogcore.State, params *Work
 	return err
 }
 
+// runVertex runs node of vertex, reports it to tracker, progress and listener, panic of node is returned as error.
+func runVertex(ctx context.Context, state ogcore.State, work *GraphVertex[ogcore.Node], params *WorkParams) (err error) {
+	if params.ContinueCond != nil {
+		waitContinue(params)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if params.Completed[work.Name] {
+		params.Progress.End(work.Name, nil)
+		return nil
+	}
+
+	tracker := params.Tracker
+	startTime := time.Now()
+
+	defer func() {
+		if info := recover(); info != nil {
+			err = fmt.Errorf("worker panic on %s, info: %v", work.Name, info)
+		}
+
+		params.Progress.End(work.Name, err)
+
+		if err != nil {
+			params.notify(EventNodeError, work.Name, time.Since(startTime), err)
+		} else {
+			tracker.Record(work.Name, "end", time.Now())
+			tracker.Record(work.Name, "complete", time.Now())
+
+			params.notify(EventNodeEnd, work.Name, time.Since(startTime), nil)
+		}
+	}()
+
+	tracker.Record(work.Name, "ready", startTime)
+	tracker.Record(work.Name, "start", startTime)
+
+	params.Progress.Start(work.Name)
+	params.notify(EventNodeStart, work.Name, 0, nil)
+
+	if work.Elem != nil {
+		if err := work.Elem.Run(ctx, state); err != nil {
+			return fmt.Errorf("%s failed, error: %w", work.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (params *WorkParams) notify(event string, name string, elapsed time.Duration, err error) {
+	if params.Listener != nil {
+		params.Listener(event, name, elapsed, err)
+	}
+}
+
 func (worker *Worker) SetTxManager(manager *TransactionManager) {
 	worker.txManager = manager
 }
@@ -0,0 +1,380 @@
+package ograph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/symphony09/ograph/internal"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var ErrJournalNotSet error = errors.New("journal of pipeline is not set")
+var ErrNotIdempotent error = errors.New("non-idempotent nodes were interrupted")
+var ErrJournalState error = errors.New("journal can't snapshot state")
+
+// Journal is a write-ahead log of runs, it records run start, node completions and state mutations into a file,
+// so that runs interrupted by crash can be resumed by Pipeline.Resume.
+//
+// Only mutations of string keys with json serializable values are recorded, values of basic types like int
+// are restored as they were, other values are restored as generic json values, e.g. map[string]any.
+// State of journaled runs should be created by NewState, so that it can be snapshot when run starts.
+type Journal struct {
+	file *internal.JournalFile
+}
+
+// JournalRun is a run recorded in journal.
+type JournalRun struct {
+	ID        string
+	Pipeline  string
+	StartTime time.Time
+
+	// Completed nodes are skipped when resumed, Running nodes were interrupted.
+	Completed []string
+	Running   []string
+
+	State map[string]any
+}
+
+// Incomplete returns runs which were not ended, in order of start.
+func (journal *Journal) Incomplete() ([]*JournalRun, error) {
+	runs, err := journal.file.Replay()
+	if err != nil {
+		return nil, err
+	}
+
+	var incomplete []*JournalRun
+
+	for _, run := range runs {
+		if run.Ended() {
+			continue
+		}
+
+		jr := &JournalRun{
+			ID:        run.ID,
+			Pipeline:  run.Pipeline,
+			StartTime: run.StartTime,
+			Completed: run.Completed,
+			Running:   run.Running,
+			State:     make(map[string]any, len(run.State)),
+		}
+
+		for key, record := range run.State {
+			if jr.State[key], err = decodeStateValue(record.Kind, record.Value); err != nil {
+				return nil, fmt.Errorf("can't decode state %s of run %s, err: %w", key, run.ID, err)
+			}
+		}
+
+		incomplete = append(incomplete, jr)
+	}
+
+	return incomplete, nil
+}
+
+// Complete marks node of run completed, e.g. after a interrupted non-idempotent node is checked by hand.
+func (journal *Journal) Complete(runID string, node string) error {
+	return journal.file.Append(internal.JournalRecord{Type: internal.RecordNodeEnd, Run: runID, Time: time.Now(), Node: node})
+}
+
+// Abandon ends run in journal, so that it's not listed as incomplete.
+func (journal *Journal) Abandon(runID string) error {
+	return journal.file.Append(internal.JournalRecord{Type: internal.RecordRunEnd, Run: runID, Time: time.Now(), Err: "abandoned"})
+}
+
+// Compact drops records of ended runs from journal file.
+func (journal *Journal) Compact() error {
+	return journal.file.Compact()
+}
+
+func (journal *Journal) Close() error {
+	return journal.file.Close()
+}
+
+// track records run into journal, it returns state which records mutations.
+// It fails with ErrJournalState if state can't be snapshot when run starts.
+func (journal *Journal) track(runID string, pipelineName string, state ogcore.State, params *internal.WorkParams,
+	logger *slog.Logger) (ogcore.State, error) {
+
+	if _, ok := state.(*BaseState); !ok {
+		return nil, fmt.Errorf("%w, type: %T", ErrJournalState, state)
+	}
+
+	writer := &journalWriter{file: journal.file, runID: runID, logger: logger}
+
+	listener := params.Listener
+
+	params.Listener = func(event string, name string, elapsed time.Duration, err error) {
+		record := internal.JournalRecord{Run: runID, Time: time.Now(), Node: name}
+
+		if err != nil {
+			record.Err = err.Error()
+		}
+
+		switch event {
+		case EventPipelineStart:
+			if params.Completed != nil {
+				record.Type = internal.RecordRunResume
+				writer.write(record)
+			} else {
+				record.Type, record.Pipeline = internal.RecordRunStart, pipelineName
+				writer.write(append([]internal.JournalRecord{record}, snapshotRecords(runID, state)...)...)
+			}
+		case EventNodeStart:
+			record.Type = internal.RecordNodeStart
+			writer.write(record)
+		case EventNodeEnd, EventNodeError:
+			record.Type = internal.RecordNodeEnd
+			writer.write(record)
+		case EventPipelineEnd:
+			record.Type = internal.RecordRunEnd
+			writer.write(record)
+		}
+
+		if listener != nil {
+			listener(event, name, elapsed, err)
+		}
+	}
+
+	return &journalState{State: state, writer: writer}, nil
+}
+
+// journalWriter writes records of a run in order, records are queued first, so that they can be queued
+// while state is locked, and written after the lock is released.
+type journalWriter struct {
+	file   *internal.JournalFile
+	runID  string
+	logger *slog.Logger
+
+	pending   []internal.JournalRecord
+	pendingMu sync.Mutex
+
+	// flushMu keeps flushes in order, a flush waits for records queued before it to be written.
+	flushMu sync.Mutex
+}
+
+func (writer *journalWriter) queue(records ...internal.JournalRecord) {
+	writer.pendingMu.Lock()
+	defer writer.pendingMu.Unlock()
+
+	writer.pending = append(writer.pending, records...)
+}
+
+func (writer *journalWriter) flush() {
+	writer.flushMu.Lock()
+	defer writer.flushMu.Unlock()
+
+	writer.pendingMu.Lock()
+	records := writer.pending
+	writer.pending = nil
+	writer.pendingMu.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+
+	if err := writer.file.Append(records...); err != nil {
+		writer.logger.Warn("journal write failed", "RunID", writer.runID, "Error", err)
+	}
+}
+
+func (writer *journalWriter) write(records ...internal.JournalRecord) {
+	writer.queue(records...)
+	writer.flush()
+}
+
+// journalState records mutations of string keys. Records are queued while key is locked, so that they are in
+// the same order as mutations, and written before Set, Update or SetBatch returns, without holding the lock.
+type journalState struct {
+	ogcore.State
+
+	writer *journalWriter
+
+	// batchLock is held exclusively by SetBatch, so that the batch record is in order with other mutations.
+	batchLock sync.RWMutex
+}
+
+func (state *journalState) Set(key any, val any) {
+	state.Update(key, func(any) any {
+		return val
+	})
+}
+
+func (state *journalState) Update(key any, updateFunc func(val any) any) {
+	state.batchLock.RLock()
+
+	state.State.Update(key, func(oldVal any) any {
+		newVal := updateFunc(oldVal)
+
+		if record, ok := stateRecord(state.writer.runID, key, newVal); ok {
+			state.writer.queue(record)
+		}
+
+		return newVal
+	})
+
+	state.batchLock.RUnlock()
+
+	state.writer.flush()
+}
+
+// SetBatch sets vals atomically if the underlying state is ogcore.BatchSetter, they are recorded as one record.
+func (state *journalState) SetBatch(vals map[any]any) {
+	state.batchLock.Lock()
+
+	var records []internal.JournalRecord
+
+	for key, val := range vals {
+		if record, ok := stateRecord(state.writer.runID, key, val); ok {
+			records = append(records, record)
+		}
+	}
+
+	if raw, err := json.Marshal(records); err == nil && len(records) > 0 {
+		state.writer.queue(internal.JournalRecord{
+			Type: internal.RecordSetBatch, Run: state.writer.runID, Time: time.Now(), Value: raw,
+		})
+	}
+
+	if batchSetter, ok := state.State.(ogcore.BatchSetter); ok {
+		batchSetter.SetBatch(vals)
+	} else {
+		for key, val := range vals {
+			state.State.Set(key, val)
+		}
+	}
+
+	state.batchLock.Unlock()
+
+	state.writer.flush()
+}
+
+func snapshotRecords(runID string, state ogcore.State) []internal.JournalRecord {
+	baseState, ok := state.(*BaseState)
+	if !ok {
+		return nil
+	}
+
+	baseState.RLock()
+	defer baseState.RUnlock()
+
+	var records []internal.JournalRecord
+
+	for key, val := range baseState.store {
+		if record, ok := stateRecord(runID, key, val); ok {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func stateRecord(runID string, key any, val any) (internal.JournalRecord, bool) {
+	strKey, ok := key.(string)
+	if !ok {
+		return internal.JournalRecord{}, false
+	}
+
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return internal.JournalRecord{}, false
+	}
+
+	var kind string
+
+	if t := reflect.TypeOf(val); t != nil && basicKinds[t.String()] != nil {
+		kind = t.String()
+	}
+
+	return internal.JournalRecord{Type: internal.RecordSet, Run: runID, Time: time.Now(), Key: strKey, Kind: kind, Value: raw}, true
+}
+
+var basicKinds = map[string]reflect.Type{}
+
+func init() {
+	for _, t := range []reflect.Type{
+		reflect.TypeFor[bool](), reflect.TypeFor[string](),
+		reflect.TypeFor[int](), reflect.TypeFor[int8](), reflect.TypeFor[int16](), reflect.TypeFor[int32](), reflect.TypeFor[int64](),
+		reflect.TypeFor[uint](), reflect.TypeFor[uint8](), reflect.TypeFor[uint16](), reflect.TypeFor[uint32](), reflect.TypeFor[uint64](),
+		reflect.TypeFor[float32](), reflect.TypeFor[float64](),
+	} {
+		basicKinds[t.String()] = t
+	}
+}
+
+func decodeStateValue(kind string, raw json.RawMessage) (any, error) {
+	if t := basicKinds[kind]; t != nil {
+		ptr := reflect.New(t)
+
+		if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+			return nil, err
+		}
+
+		return ptr.Elem().Interface(), nil
+	}
+
+	var val any
+	err := json.Unmarshal(raw, &val)
+
+	return val, err
+}
+
+// Resume continues incomplete run from journal, state is restored and completed nodes are skipped.
+// It fails with ErrNotIdempotent if non-idempotent nodes were interrupted, see Journal.Complete and Journal.Abandon.
+func (pipeline *Pipeline) Resume(ctx context.Context, run *JournalRun) error {
+	if pipeline.Journal == nil {
+		return ErrJournalNotSet
+	}
+
+	var interrupted []string
+
+	for _, name := range run.Running {
+		if e := pipeline.elements[name]; e != nil && e.NonIdempotent {
+			interrupted = append(interrupted, name)
+		}
+	}
+
+	if len(interrupted) > 0 {
+		return fmt.Errorf("%w, run: %s, nodes: %s", ErrNotIdempotent, run.ID, strings.Join(interrupted, ", "))
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	state := NewState()
+
+	for key, val := range run.State {
+		state.store[key] = val
+	}
+
+	newCtx, newState, worker, params, afterRun, err := pipeline.prepare(ogcore.WithRunID(ctx, run.ID), state)
+	if err != nil {
+		return err
+	}
+
+	defer afterRun()
+
+	params.Completed = make(map[string]bool, len(run.Completed))
+
+	for _, name := range run.Completed {
+		params.Completed[name] = true
+	}
+
+	return worker.Work(newCtx, newState, params)
+}
+
+// OpenJournal opens journal file, it's created if not exists. Every write is synced to disk.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := internal.OpenJournalFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{file: file}, nil
+}
@@ -0,0 +1,40 @@
+package ogcore
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand/v2"
+)
+
+type determinismKey struct{}
+
+// Determinism asks nodes to run their sub nodes one at a time in a reproducible order.
+// If Seed is 0, the declared order is used, otherwise the order is a permutation generated from Seed.
+type Determinism struct {
+	Seed int64
+}
+
+// Perm returns the order to run n sub nodes of the node named key.
+func (d Determinism) Perm(key string, n int) []int {
+	if d.Seed == 0 {
+		perm := make([]int, n)
+		for i := range perm {
+			perm[i] = i
+		}
+		return perm
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	return rand.New(rand.NewPCG(uint64(d.Seed), h.Sum64())).Perm(n)
+}
+
+func WithDeterminism(ctx context.Context, d Determinism) context.Context {
+	return context.WithValue(ctx, determinismKey{}, d)
+}
+
+func DeterminismFrom(ctx context.Context) (Determinism, bool) {
+	d, ok := ctx.Value(determinismKey{}).(Determinism)
+	return d, ok
+}
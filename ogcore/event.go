@@ -0,0 +1,9 @@
+package ogcore
+
+// Event is the object of events emitted with payload, it embeds state of the run,
+// so subscribers which only care about state can use it as State.
+type Event struct {
+	State
+
+	Payload any
+}
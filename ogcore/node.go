@@ -39,6 +39,14 @@ type Transactional interface {
 	Rollback()
 }
 
+// ContextTransactional is like Transactional, but commit and rollback are aware of ctx and can fail.
+// Failures are returned in the error of run.
+type ContextTransactional interface {
+	Node
+	CommitContext(ctx context.Context) error
+	RollbackContext(ctx context.Context) error
+}
+
 type EventNode interface {
 	Node
 	AttachBus(bus *eventd.EventBus[State])
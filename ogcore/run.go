@@ -0,0 +1,15 @@
+package ogcore
+
+import "context"
+
+type runIDKey struct{}
+
+// WithRunID sets ID of the run, pipelines generate one if it's not set, nested pipelines share the ID of outer run.
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+func RunIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
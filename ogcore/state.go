@@ -5,3 +5,8 @@ type State interface {
 	Set(key any, val any)
 	Update(key any, updateFunc func(val any) any)
 }
+
+// BatchSetter is implemented by states which can set multiple keys atomically.
+type BatchSetter interface {
+	SetBatch(vals map[any]any)
+}
@@ -1,10 +1,15 @@
 package ogcore
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type Tracker struct {
 	StartTime time.Time
 	TraceData []EventTrace
+
+	mu sync.Mutex
 }
 
 type EventTrace struct {
@@ -18,6 +23,9 @@ func (tracker *Tracker) Record(nodeName string, event string, timestamp time.Tim
 		return
 	}
 
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
 	tracker.TraceData = append(tracker.TraceData, EventTrace{
 		NodeName:  nodeName,
 		Event:     event,
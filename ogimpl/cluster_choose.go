@@ -5,19 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/internal"
 	"github.com/symphony09/ograph/ogcore"
-
-	"github.com/expr-lang/expr"
-	"github.com/expr-lang/expr/ast"
-	"github.com/expr-lang/expr/parser"
+	"golang.org/x/sync/errgroup"
 )
 
 var ChooseClusterFactory = func() ogcore.Node {
 	return &ChooseCluster{}
 }
 
+// ChooseCluster runs the chosen sub node.
+//
+// In index mode, ChooseExpr or ChooseFn returns 1-based index of sub node to run.
+// In switch mode, SwitchExpr or SwitchFn returns case to run, which is matched against sub node names and
+// case labels in Cases, SwitchExpr can also return a list of cases. If no sub node matches, Default is run if set.
+// Only the first matched sub node in declared order runs, unless MultiMatch is set, then all of them run in parallel.
 type ChooseCluster struct {
 	ograph.BaseCluster
 	*slog.Logger
@@ -25,64 +30,165 @@ type ChooseCluster struct {
 	ChooseExpr string
 
 	ChooseFn func(ctx context.Context, state ogcore.State) int
+
+	SwitchExpr string
+
+	SwitchFn func(ctx context.Context, state ogcore.State) (string, error)
+
+	// Cases maps sub node names to case labels, sub nodes with the same label are matched together.
+	Cases map[string]string
+	// Default is name of sub node to run when no case matches.
+	Default    string
+	MultiMatch bool
+
+	chooseFn func(ctx context.Context, state ogcore.State) (int, error)
+	switchFn func(ctx context.Context, state ogcore.State) ([]string, error)
 }
 
 func (cluster *ChooseCluster) Init(params map[string]any) error {
-	if fn, ok := params["ChooseFn"].(func(ctx context.Context, state ogcore.State) int); ok {
-		cluster.ChooseFn = fn
-		return nil
+	if cases, ok := params["Cases"].(map[string]string); ok {
+		cluster.Cases = cases
+	} else if cases, ok := params["Cases"].(map[string]any); ok {
+		cluster.Cases = make(map[string]string, len(cases))
+
+		for name, label := range cases {
+			if cluster.Cases[name], ok = label.(string); !ok {
+				return fmt.Errorf("case label of %s should be string, got: %v", name, label)
+			}
+		}
 	}
 
-	if exprStr, ok := params["ChooseExpr"].(string); ok {
-		cluster.ChooseExpr = exprStr
+	if defaultCase, ok := params["Default"].(string); ok {
+		cluster.Default = defaultCase
+	}
 
-		program, err := expr.Compile(exprStr)
-		if err != nil {
-			return err
-		}
+	if multiMatch, ok := params["MultiMatch"].(bool); ok {
+		cluster.MultiMatch = multiMatch
+	}
 
-		tree, err := parser.Parse(exprStr)
+	if fn, ok := params["SwitchFn"].(func(ctx context.Context, state ogcore.State) (string, error)); ok {
+		cluster.SwitchFn = fn
+	} else if exprStr, ok := params["SwitchExpr"].(string); ok {
+		cluster.SwitchExpr = exprStr
+
+		stateExpr, err := internal.CompileStateExpr(exprStr)
 		if err != nil {
 			return err
 		}
 
-		v := &Visitor{}
-		ast.Walk(&tree.Node, v)
+		cluster.switchFn = func(ctx context.Context, state ogcore.State) ([]string, error) {
+			output, err := stateExpr.Run(state, nil)
+			if err != nil {
+				return nil, err
+			}
 
-		cluster.ChooseFn = func(ctx context.Context, state ogcore.State) int {
-			env := make(map[string]any)
+			switch ret := output.(type) {
+			case string:
+				return []string{ret}, nil
+			case []string:
+				return ret, nil
+			case []any:
+				cases := make([]string, 0, len(ret))
 
-			for _, identifier := range v.Identifiers {
-				env[identifier], _ = state.Get(identifier)
+				for _, c := range ret {
+					if s, ok := c.(string); ok {
+						cases = append(cases, s)
+					} else {
+						return nil, fmt.Errorf("unknown case: %v", c)
+					}
+				}
+
+				return cases, nil
+			case nil:
+				return nil, nil
 			}
 
-			output, err := expr.Run(program, env)
+			return nil, fmt.Errorf("unknown result: %v", output)
+		}
+
+		return nil
+	}
+
+	if cluster.SwitchFn != nil {
+		cluster.switchFn = switchFnOf(cluster.SwitchFn)
+		return nil
+	}
+
+	if fn, ok := params["ChooseFn"].(func(ctx context.Context, state ogcore.State) int); ok {
+		cluster.ChooseFn = fn
+	} else if exprStr, ok := params["ChooseExpr"].(string); ok {
+		cluster.ChooseExpr = exprStr
+
+		stateExpr, err := internal.CompileStateExpr(exprStr)
+		if err != nil {
+			return err
+		}
+
+		cluster.chooseFn = func(ctx context.Context, state ogcore.State) (int, error) {
+			output, err := stateExpr.Run(state, nil)
 			if err != nil {
-				panic(err)
+				return 0, err
 			}
 
 			if ret, ok := output.(int); ok {
-				return ret
+				return ret, nil
 			}
 
-			panic(fmt.Errorf("unknown result: %v", output))
+			return 0, fmt.Errorf("unknown result: %v", output)
 		}
 
 		return nil
 	}
 
+	if cluster.ChooseFn != nil {
+		cluster.chooseFn = chooseFnOf(cluster.ChooseFn)
+		return nil
+	}
+
 	return errors.New("choose expr or function not set")
 }
 
+func switchFnOf(fn func(ctx context.Context, state ogcore.State) (string, error)) func(ctx context.Context, state ogcore.State) ([]string, error) {
+	return func(ctx context.Context, state ogcore.State) ([]string, error) {
+		c, err := fn(ctx, state)
+		return []string{c}, err
+	}
+}
+
+func chooseFnOf(fn func(ctx context.Context, state ogcore.State) int) func(ctx context.Context, state ogcore.State) (int, error) {
+	return func(ctx context.Context, state ogcore.State) (int, error) {
+		return fn(ctx, state), nil
+	}
+}
+
 func (cluster *ChooseCluster) Run(ctx context.Context, state ogcore.State) error {
 	if cluster.Logger == nil {
 		cluster.Logger = slog.Default()
 	}
 
+	switchFn, chooseFn := cluster.switchFn, cluster.chooseFn
+
+	// singleton clusters are not initialized, their exported functions are used directly
+	if switchFn == nil && chooseFn == nil {
+		if cluster.SwitchFn != nil {
+			switchFn = switchFnOf(cluster.SwitchFn)
+		} else if cluster.ChooseFn != nil {
+			chooseFn = chooseFnOf(cluster.ChooseFn)
+		}
+	}
+
+	if switchFn != nil {
+		return cluster.runSwitch(ctx, state, switchFn)
+	}
+
 	var chosenNode ogcore.Node
 
-	if cluster.ChooseFn != nil {
-		n := cluster.ChooseFn(ctx, state)
+	if chooseFn != nil {
+		n, err := chooseFn(ctx, state)
+		if err != nil {
+			return fmt.Errorf("choose cluster (%s) failed to choose, err: %w", cluster.Name(), err)
+		}
+
 		if n > 0 && n <= len(cluster.Group) {
 			chosenNode = cluster.Group[n-1]
 		}
@@ -92,10 +198,7 @@ func (cluster *ChooseCluster) Run(ctx context.Context, state ogcore.State) error
 		return nil
 	}
 
-	nodeName := "unknown"
-	if nameable, ok := chosenNode.(ogcore.Nameable); ok {
-		nodeName = nameable.Name()
-	}
+	nodeName := nodeNameOf(chosenNode)
 
 	if err := chosenNode.Run(ctx, state); err != nil {
 		return fmt.Errorf("chosen node (%s) failed, err: %w", nodeName, err)
@@ -105,6 +208,84 @@ func (cluster *ChooseCluster) Run(ctx context.Context, state ogcore.State) error
 	}
 }
 
+func (cluster *ChooseCluster) runSwitch(ctx context.Context, state ogcore.State,
+	switchFn func(ctx context.Context, state ogcore.State) ([]string, error)) error {
+
+	cases, err := switchFn(ctx, state)
+	if err != nil {
+		return fmt.Errorf("choose cluster (%s) failed to choose, err: %w", cluster.Name(), err)
+	}
+
+	var chosenNodes []ogcore.Node
+
+	for _, node := range cluster.Group {
+		nodeName := nodeNameOf(node)
+		label, hasLabel := cluster.Cases[nodeName]
+
+		if slices.Contains(cases, nodeName) || hasLabel && slices.Contains(cases, label) {
+			chosenNodes = append(chosenNodes, node)
+
+			if !cluster.MultiMatch {
+				break
+			}
+		}
+	}
+
+	if len(chosenNodes) == 0 && cluster.Default != "" {
+		defaultNode, ok := cluster.NodeMap[cluster.Default]
+		if !ok {
+			return fmt.Errorf("default node (%s) of choose cluster (%s) not found", cluster.Default, cluster.Name())
+		}
+
+		chosenNodes = append(chosenNodes, defaultNode)
+	}
+
+	runNode := func(ctx context.Context, node ogcore.Node) error {
+		if err := node.Run(ctx, state); err != nil {
+			return fmt.Errorf("chosen node (%s) failed, err: %w", nodeNameOf(node), err)
+		}
+
+		return nil
+	}
+
+	if len(chosenNodes) == 1 {
+		if err := runNode(ctx, chosenNodes[0]); err != nil {
+			return err
+		}
+	} else if _, ok := ogcore.DeterminismFrom(ctx); ok {
+		// run chosen nodes one by one in declared order
+		for _, node := range chosenNodes {
+			if err := runNode(ctx, node); err != nil {
+				return err
+			}
+		}
+	} else if len(chosenNodes) > 1 {
+		g, ctx := errgroup.WithContext(ctx)
+
+		for _, node := range chosenNodes {
+			g.Go(func() error {
+				return runNode(ctx, node)
+			})
+		}
+
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+
+	if len(chosenNodes) > 0 {
+		chosen := make([]string, 0, len(chosenNodes))
+
+		for _, node := range chosenNodes {
+			chosen = append(chosen, nodeNameOf(node))
+		}
+
+		cluster.Info("choose cluster finish", "Chosen", chosen)
+	}
+
+	return nil
+}
+
 func NewChooseClusterFactory(
 	chooseFn func(ctx context.Context, state ogcore.State) int,
 ) func() ogcore.Node {
@@ -115,3 +296,19 @@ func NewChooseClusterFactory(
 		}
 	}
 }
+
+// NewSwitchClusterFactory returns factory of choose cluster in switch mode, cases maps sub node names to case labels.
+func NewSwitchClusterFactory(
+	switchFn func(ctx context.Context, state ogcore.State) (string, error),
+	cases map[string]string,
+	defaultCase string,
+) func() ogcore.Node {
+
+	return func() ogcore.Node {
+		return &ChooseCluster{
+			SwitchFn: switchFn,
+			Cases:    cases,
+			Default:  defaultCase,
+		}
+	}
+}
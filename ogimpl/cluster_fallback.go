@@ -0,0 +1,102 @@
+package ogimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var FallbackClusterFactory = func() ogcore.Node {
+	return &FallbackCluster{}
+}
+
+// FallbackCluster runs its first sub node as primary, if it fails, the other sub nodes are run in order
+// until one of them succeeds.
+//
+// If any of FallbackIf and FallbackOn is set, only errors matching one of them fall back, other errors are returned.
+// The error of primary node is set in state by ErrorKey before running fallbacks, ErrorKey defaults to
+// cluster name + "_error".
+type FallbackCluster struct {
+	ograph.BaseCluster
+	*slog.Logger
+
+	FallbackIf func(err error) bool
+	FallbackOn []error
+
+	ErrorKey string
+}
+
+func (cluster *FallbackCluster) Run(ctx context.Context, state ogcore.State) error {
+	if cluster.Logger == nil {
+		cluster.Logger = slog.Default()
+	}
+
+	if len(cluster.Group) == 0 {
+		return nil
+	}
+
+	primaryErr := cluster.Group[0].Run(ctx, state)
+	if primaryErr == nil || ctx.Err() != nil || !cluster.shouldFallback(primaryErr) {
+		return primaryErr
+	}
+
+	key := cluster.ErrorKey
+	if key == "" {
+		key = cluster.Name() + "_error"
+	}
+
+	state.Set(key, primaryErr)
+
+	errs := []error{fmt.Errorf("primary node (%s) failed, err: %w", nodeNameOf(cluster.Group[0]), primaryErr)}
+
+	for _, node := range cluster.Group[1:] {
+		nodeName := nodeNameOf(node)
+
+		cluster.Warn("fallback to node",
+			"FallbackCluster", cluster.Name(), "FallbackNode", nodeName, "Error", errs[len(errs)-1])
+
+		err := node.Run(ctx, state)
+		if err == nil {
+			cluster.Info("fallback cluster finish", "Fallback", nodeName)
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("fallback node (%s) failed, err: %w", nodeName, err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cluster *FallbackCluster) shouldFallback(err error) bool {
+	if cluster.FallbackIf == nil && len(cluster.FallbackOn) == 0 {
+		return true
+	}
+
+	if cluster.FallbackIf != nil && cluster.FallbackIf(err) {
+		return true
+	}
+
+	for _, target := range cluster.FallbackOn {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nodeNameOf(node ogcore.Node) string {
+	if nameable, ok := node.(ogcore.Nameable); ok {
+		return nameable.Name()
+	}
+
+	return "unknown"
+}
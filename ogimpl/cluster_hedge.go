@@ -0,0 +1,283 @@
+package ogimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/symphony09/eventd"
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var HedgeClusterFactory = func() ogcore.Node {
+	return &HedgeCluster{}
+}
+
+// HedgeCluster runs its first sub node, and starts the next one only if previous ones have not finished after
+// HedgeDelay, or at once if previous one failed. The first succeeded one wins, others are canceled and awaited.
+//
+// Each sub node runs on its own overlay state, only writes of the winner are applied to state.
+//
+// If HedgePercentile is set, e.g. 95, the delay is learned as the percentile of durations of past winners,
+// HedgeDelay is used until there are MinSamples samples. Clusters with the same LatencyKey share samples across
+// pipelines, without LatencyKey, samples are shared by runs of the cluster in its pipeline only.
+type HedgeCluster struct {
+	ograph.BaseCluster
+	*slog.Logger
+
+	HedgeDelay time.Duration
+
+	HedgePercentile float64
+	// MinSamples is 10 by default.
+	MinSamples int
+	LatencyKey string
+
+	// bus identifies the pipeline which the cluster belongs to.
+	bus *eventd.EventBus[ogcore.State]
+}
+
+func (cluster *HedgeCluster) AttachBus(bus *eventd.EventBus[ogcore.State]) {
+	cluster.bus = bus
+}
+
+type hedgeResult struct {
+	index   int
+	err     error
+	elapsed time.Duration
+}
+
+func (cluster *HedgeCluster) Run(ctx context.Context, state ogcore.State) error {
+	if cluster.Logger == nil {
+		cluster.Logger = slog.Default()
+	}
+
+	if len(cluster.Group) == 0 {
+		return nil
+	}
+
+	// run sub nodes one by one in declared order, the first succeeded one wins
+	if _, ok := ogcore.DeterminismFrom(ctx); ok {
+		return cluster.runInOrder(ctx, state)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, len(cluster.Group))
+	overlays := make([]*OverlayState, len(cluster.Group))
+
+	start := func(i int) {
+		overlays[i] = NewOverlayState(state)
+
+		go func() {
+			startTime := time.Now()
+			err := cluster.Group[i].Run(ctx, overlays[i])
+			results <- hedgeResult{index: i, err: err, elapsed: time.Since(startTime)}
+		}()
+	}
+
+	delay := cluster.delay()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	start(0)
+	started, finished := 1, 0
+
+	var errs []error
+
+	for finished < started {
+		var timerC <-chan time.Time
+
+		if started < len(cluster.Group) {
+			timerC = timer.C
+		}
+
+		select {
+		case <-timerC:
+			cluster.Info("hedge node started", "HedgeCluster", cluster.Name(), "HedgeNode", nodeNameOf(cluster.Group[started]))
+
+			start(started)
+			started++
+			timer.Reset(delay)
+		case result := <-results:
+			finished++
+
+			nodeName := nodeNameOf(cluster.Group[result.index])
+
+			if result.err == nil {
+				cancel()
+
+				overlays[result.index].Sync()
+
+				// losers are awaited, so that they don't outlive the cluster
+				for ; finished < started; finished++ {
+					<-results
+				}
+
+				if cluster.HedgePercentile > 0 {
+					hedgeLatencies.get(cluster.latencyKey()).add(result.elapsed)
+				}
+
+				cluster.Info("hedge cluster finish", "Winner", nodeName, "Started", started)
+				return nil
+			}
+
+			cluster.Warn("hedge node failed",
+				"HedgeCluster", cluster.Name(), "HedgeNode", nodeName, "Error", result.err)
+
+			errs = append(errs, fmt.Errorf("hedge node (%s) failed, err: %w", nodeName, result.err))
+
+			if started < len(cluster.Group) && ctx.Err() == nil {
+				start(started)
+				started++
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	return fmt.Errorf("all hedge nodes failed, err: %w", errors.Join(errs...))
+}
+
+func (cluster *HedgeCluster) runInOrder(ctx context.Context, state ogcore.State) error {
+	var errs []error
+
+	for _, node := range cluster.Group {
+		overlay := NewOverlayState(state)
+
+		if err := node.Run(ctx, overlay); err != nil {
+			errs = append(errs, fmt.Errorf("hedge node (%s) failed, err: %w", nodeNameOf(node), err))
+		} else {
+			overlay.Sync()
+			cluster.Info("hedge cluster finish", "Winner", nodeNameOf(node))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("all hedge nodes failed, err: %w", errors.Join(errs...))
+}
+
+// latencyKey returns key of samples in registry, samples without LatencyKey are scoped to event bus of pipeline.
+func (cluster *HedgeCluster) latencyKey() latencyKey {
+	if cluster.LatencyKey != "" {
+		return latencyKey{name: cluster.LatencyKey}
+	}
+
+	if cluster.bus == nil {
+		return latencyKey{scope: cluster, name: cluster.Name()}
+	}
+
+	return latencyKey{scope: cluster.bus, name: cluster.Name()}
+}
+
+func (cluster *HedgeCluster) delay() time.Duration {
+	if cluster.HedgePercentile <= 0 {
+		return cluster.HedgeDelay
+	}
+
+	minSamples := cluster.MinSamples
+	if minSamples <= 0 {
+		minSamples = 10
+	}
+
+	if delay, ok := hedgeLatencies.get(cluster.latencyKey()).percentile(cluster.HedgePercentile, minSamples); ok {
+		return delay
+	}
+
+	return cluster.HedgeDelay
+}
+
+// latencyWindow keeps durations of recent winners.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	used    time.Time
+
+	sync.Mutex
+}
+
+const hedgeSampleSize = 100
+
+func (window *latencyWindow) add(d time.Duration) {
+	window.Lock()
+	defer window.Unlock()
+
+	window.used = time.Now()
+
+	if len(window.samples) < hedgeSampleSize {
+		window.samples = append(window.samples, d)
+	} else {
+		window.samples[window.next] = d
+		window.next = (window.next + 1) % hedgeSampleSize
+	}
+}
+
+func (window *latencyWindow) percentile(p float64, minSamples int) (time.Duration, bool) {
+	window.Lock()
+	sorted := slices.Clone(window.samples)
+	window.Unlock()
+
+	if len(sorted) == 0 || len(sorted) < minSamples {
+		return 0, false
+	}
+
+	slices.Sort(sorted)
+
+	i := int(float64(len(sorted))*min(p, 100)/100+0.5) - 1
+
+	return sorted[max(0, min(i, len(sorted)-1))], true
+}
+
+// idle reports whether no sample is added to the window for ttl.
+func (window *latencyWindow) idle(now time.Time, ttl time.Duration) bool {
+	window.Lock()
+	defer window.Unlock()
+
+	return now.Sub(window.used) > ttl
+}
+
+type latencyKey struct {
+	scope any
+	name  string
+}
+
+// hedgeSampleTTL is how long samples scoped to a pipeline are kept without new samples.
+const hedgeSampleTTL = 10 * time.Minute
+
+// latencyRegistry never removes samples with LatencyKey, they may be used by pipelines at any time.
+// Samples scoped to pipelines are removed after hedgeSampleTTL without new samples, so that they don't outlive
+// their pipelines.
+type latencyRegistry struct {
+	windows map[latencyKey]*latencyWindow
+
+	sync.Mutex
+}
+
+var hedgeLatencies = &latencyRegistry{windows: make(map[latencyKey]*latencyWindow)}
+
+func (registry *latencyRegistry) get(key latencyKey) *latencyWindow {
+	registry.Lock()
+	defer registry.Unlock()
+
+	window := registry.windows[key]
+
+	if window == nil {
+		now := time.Now()
+
+		for k, w := range registry.windows {
+			if k.scope != nil && w.idle(now, hedgeSampleTTL) {
+				delete(registry.windows, k)
+			}
+		}
+
+		window = &latencyWindow{used: now}
+		registry.windows[key] = window
+	}
+
+	return window
+}
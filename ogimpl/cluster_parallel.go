@@ -18,25 +18,40 @@ type ParallelCluster struct {
 }
 
 func (cluster *ParallelCluster) Run(ctx context.Context, state ogcore.State) error {
+	// run sub nodes one by one in reproducible order
+	if d, ok := ogcore.DeterminismFrom(ctx); ok {
+		for _, i := range d.Perm(cluster.Name(), len(cluster.Group)) {
+			if err := cluster.runNode(ctx, state, cluster.Group[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	for _, node := range cluster.Group {
 		node := node
 
 		g.Go(func() error {
-			err := node.Run(ctx, state)
-			if err != nil {
-				nodeName := "unknown"
-				if nameable, ok := node.(ogcore.Nameable); ok {
-					nodeName = nameable.Name()
-				}
-
-				return fmt.Errorf("sub node (%s) failed, err: %w", nodeName, err)
-			} else {
-				return nil
-			}
+			return cluster.runNode(ctx, state, node)
 		})
 	}
 
 	return g.Wait()
 }
+
+func (cluster *ParallelCluster) runNode(ctx context.Context, state ogcore.State, node ogcore.Node) error {
+	err := node.Run(ctx, state)
+	if err != nil {
+		nodeName := "unknown"
+		if nameable, ok := node.(ogcore.Nameable); ok {
+			nodeName = nameable.Name()
+		}
+
+		return fmt.Errorf("sub node (%s) failed, err: %w", nodeName, err)
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,233 @@
+package ogimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var QuorumClusterFactory = func() ogcore.Node {
+	return &QuorumCluster{CancelRest: true}
+}
+
+var ErrQuorumFailed = errors.New("quorum not reached")
+
+// QuorumOutcome is the outcome of a member of quorum cluster.
+type QuorumOutcome struct {
+	Node string
+
+	// Finished is false if the member was still running when the quorum was decided.
+	Finished bool
+	Err      error
+	Elapsed  time.Duration
+}
+
+// QuorumError is returned when the quorum becomes impossible, it matches ErrQuorumFailed.
+type QuorumError struct {
+	Required  int
+	Succeeded int
+	Outcomes  []QuorumOutcome
+}
+
+func (err *QuorumError) Error() string {
+	var errs []error
+
+	for _, outcome := range err.Outcomes {
+		if outcome.Err != nil {
+			errs = append(errs, fmt.Errorf("quorum node (%s) failed, err: %w", outcome.Node, outcome.Err))
+		}
+	}
+
+	return fmt.Sprintf("%v, required: %d, succeeded: %d, err: %v", ErrQuorumFailed, err.Required, err.Succeeded, errors.Join(errs...))
+}
+
+func (err *QuorumError) Is(target error) bool {
+	return target == ErrQuorumFailed
+}
+
+func (err *QuorumError) Unwrap() []error {
+	var errs []error
+
+	for _, outcome := range err.Outcomes {
+		if outcome.Err != nil {
+			errs = append(errs, outcome.Err)
+		}
+	}
+
+	return errs
+}
+
+// QuorumCluster runs all sub nodes at once, it succeeds as soon as Required of them succeed,
+// and fails with QuorumError as soon as that becomes impossible. Required defaults to majority of sub nodes.
+//
+// With CancelRest, which is set by default, sub nodes still running are canceled once the result is decided,
+// otherwise they run to the end. Either way they are awaited, sub nodes never outlive the cluster.
+//
+// Outcomes of sub nodes at the time of decision are set in state by OutcomesKey as []QuorumOutcome,
+// which defaults to cluster name + "_outcomes".
+type QuorumCluster struct {
+	ograph.BaseCluster
+	*slog.Logger
+
+	Required   int
+	CancelRest bool
+
+	OutcomesKey string
+}
+
+func (cluster *QuorumCluster) Run(ctx context.Context, state ogcore.State) error {
+	if cluster.Logger == nil {
+		cluster.Logger = slog.Default()
+	}
+
+	required := cluster.Required
+	if required <= 0 {
+		required = len(cluster.Group)/2 + 1
+	}
+
+	if required > len(cluster.Group) {
+		return fmt.Errorf("required %d of quorum cluster %s is more than its %d sub nodes", required, cluster.Name(), len(cluster.Group))
+	}
+
+	quorum := &quorumTracker{
+		required: required,
+		outcomes: make([]QuorumOutcome, len(cluster.Group)),
+		decided:  make(chan struct{}),
+	}
+
+	for i, node := range cluster.Group {
+		quorum.outcomes[i].Node = nodeNameOf(node)
+	}
+
+	// run sub nodes one by one in reproducible order, until the result is decided
+	if d, ok := ogcore.DeterminismFrom(ctx); ok {
+		for _, i := range d.Perm(cluster.Name(), len(cluster.Group)) {
+			startTime := time.Now()
+			err := cluster.Group[i].Run(ctx, state)
+
+			if quorum.done(i, err, time.Since(startTime)) {
+				break
+			}
+		}
+
+		return cluster.finish(state, quorum)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for i, node := range cluster.Group {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			startTime := time.Now()
+			err := node.Run(ctx, state)
+
+			if err != nil {
+				cluster.Warn("quorum node failed",
+					"QuorumCluster", cluster.Name(), "QuorumNode", quorum.outcomes[i].Node, "Error", err)
+			}
+
+			quorum.done(i, err, time.Since(startTime))
+		}()
+	}
+
+	<-quorum.decided
+
+	// outcomes are taken at the time of decision, sub nodes finished after it are not counted
+	if cluster.CancelRest {
+		cancel()
+	}
+
+	wg.Wait()
+
+	return cluster.finish(state, quorum)
+}
+
+func (cluster *QuorumCluster) finish(state ogcore.State, quorum *quorumTracker) error {
+	outcomes, succeeded := quorum.snapshot()
+
+	key := cluster.OutcomesKey
+	if key == "" {
+		key = cluster.Name() + "_outcomes"
+	}
+
+	state.Set(key, outcomes)
+
+	if succeeded < quorum.required {
+		return &QuorumError{Required: quorum.required, Succeeded: succeeded, Outcomes: outcomes}
+	}
+
+	cluster.Info("quorum cluster finish", "Required", quorum.required, "Succeeded", succeeded)
+
+	return nil
+}
+
+type quorumTracker struct {
+	required  int
+	succeeded int
+	failed    int
+	outcomes  []QuorumOutcome
+
+	// decided is closed once quorum is reached or becomes impossible, outcomes are copied into decision then.
+	decided   chan struct{}
+	isDecided bool
+	decision  []QuorumOutcome
+
+	sync.Mutex
+}
+
+// done records outcome of sub node i, it reports whether the result is decided.
+func (quorum *quorumTracker) done(i int, err error, elapsed time.Duration) bool {
+	quorum.Lock()
+	defer quorum.Unlock()
+
+	quorum.outcomes[i].Finished, quorum.outcomes[i].Err, quorum.outcomes[i].Elapsed = true, err, elapsed
+
+	if err != nil {
+		quorum.failed++
+	} else {
+		quorum.succeeded++
+	}
+
+	if !quorum.isDecided && (quorum.succeeded >= quorum.required ||
+		len(quorum.outcomes)-quorum.failed < quorum.required) {
+
+		quorum.isDecided = true
+		quorum.decision = append([]QuorumOutcome(nil), quorum.outcomes...)
+		close(quorum.decided)
+	}
+
+	return quorum.isDecided
+}
+
+// snapshot returns outcomes at the time of decision and number of succeeded sub nodes in them.
+func (quorum *quorumTracker) snapshot() ([]QuorumOutcome, int) {
+	quorum.Lock()
+	defer quorum.Unlock()
+
+	outcomes := quorum.decision
+	if outcomes == nil {
+		outcomes = append([]QuorumOutcome(nil), quorum.outcomes...)
+	}
+
+	succeeded := 0
+
+	for _, outcome := range outcomes {
+		if outcome.Finished && outcome.Err == nil {
+			succeeded++
+		}
+	}
+
+	return outcomes, succeeded
+}
@@ -3,9 +3,9 @@ package ogimpl
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
-	"sync/atomic"
 
 	"github.com/symphony09/ograph"
 	"github.com/symphony09/ograph/ogcore"
@@ -15,11 +15,21 @@ var RaceClusterFactory = func() ogcore.Node {
 	return &RaceCluster{}
 }
 
+// RaceCluster runs all sub nodes at once, the first succeeded one wins, others are canceled and awaited.
+// A loser which ignores ctx blocks the cluster until it returns, sub nodes never outlive the cluster.
+//
+// With StateIsolation, each sub node runs on its own overlay state, writes of the winner are committed into
+// state atomically and writes of others are discarded. Without it, sub nodes write state directly,
+// writes after the winner is decided are dropped.
+//
+// The winner name is set in state by WinnerKey, which defaults to cluster name + "_winner".
 type RaceCluster struct {
 	ograph.BaseCluster
 	*slog.Logger
 
 	StateIsolation bool
+
+	WinnerKey string
 }
 
 func (cluster *RaceCluster) Run(ctx context.Context, state ogcore.State) error {
@@ -27,60 +37,157 @@ func (cluster *RaceCluster) Run(ctx context.Context, state ogcore.State) error {
 		cluster.Logger = slog.Default()
 	}
 
+	// run sub nodes one by one in reproducible order, the first succeeded one wins
+	if d, ok := ogcore.DeterminismFrom(ctx); ok {
+		return cluster.runInOrder(ctx, state, d)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	raceCh := make(chan struct{})
+	race := &raceState{State: state}
 
-	var failures atomic.Uint32
-	var once sync.Once
-	var winner string
+	var wg sync.WaitGroup
+	var errsLock sync.Mutex
+	var errs []error
 
 	for _, node := range cluster.Group {
+		wg.Add(1)
+
 		go func(node ogcore.Node) {
+			defer wg.Done()
+
+			nodeName := nodeNameOf(node)
+
+			var overlay *OverlayState
 			var clusterState ogcore.State
 
 			if cluster.StateIsolation {
-				clusterState = NewOverlayState(state)
+				overlay = NewOverlayState(state)
+				clusterState = overlay
 			} else {
-				clusterState = NewGuardState(state, func(key any) (flag int) {
-					if ctx.Err() != nil {
-						return AllowRead
-					} else {
-						return AllowRead | AllowWrite
-					}
-				})
-			}
-
-			nodeName := "unknown"
-
-			if nameable, ok := node.(ogcore.Nameable); ok {
-				nodeName = nameable.Name()
+				clusterState = &raceMemberState{race: race}
 			}
 
 			if err := node.Run(ctx, clusterState); err != nil {
 				cluster.Warn("race node failed",
 					"RaceCluster", cluster.Name(), "RaceNode", nodeName, "Error", err)
 
-				if int(failures.Add(1)) == len(cluster.Group) {
-					close(raceCh)
+				errsLock.Lock()
+				errs = append(errs, fmt.Errorf("race node (%s) failed, err: %w", nodeName, err))
+				errsLock.Unlock()
+			} else if race.decide(nodeName) {
+				cancel()
+
+				if overlay != nil {
+					overlay.Set(cluster.winnerKey(), nodeName)
+					overlay.Sync()
+				} else {
+					state.Set(cluster.winnerKey(), nodeName)
 				}
-			} else {
-				once.Do(func() {
-					winner = nodeName
-					close(raceCh)
-				})
+
 			}
 		}(node)
 	}
 
-	<-raceCh
+	wg.Wait()
+
+	if race.winner == "" {
+		errsLock.Lock()
+		defer errsLock.Unlock()
 
-	if int(failures.Load()) == len(cluster.Group) {
-		return errors.New("all race nodes failed")
+		return fmt.Errorf("all race nodes failed, err: %w", errors.Join(errs...))
 	}
 
-	cluster.Info("race cluster finish", "Winner", winner)
+	cluster.Info("race cluster finish", "Winner", race.winner)
 
 	return nil
 }
+
+func (cluster *RaceCluster) runInOrder(ctx context.Context, state ogcore.State, d ogcore.Determinism) error {
+	var errs []error
+
+	for _, i := range d.Perm(cluster.Name(), len(cluster.Group)) {
+		node := cluster.Group[i]
+		nodeName := nodeNameOf(node)
+
+		overlay := NewOverlayState(state)
+
+		var clusterState ogcore.State = state
+
+		if cluster.StateIsolation {
+			clusterState = overlay
+		}
+
+		if err := node.Run(ctx, clusterState); err != nil {
+			cluster.Warn("race node failed",
+				"RaceCluster", cluster.Name(), "RaceNode", nodeName, "Error", err)
+
+			errs = append(errs, fmt.Errorf("race node (%s) failed, err: %w", nodeName, err))
+		} else {
+			overlay.Set(cluster.winnerKey(), nodeName)
+			overlay.Sync()
+
+			cluster.Info("race cluster finish", "Winner", nodeName)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("all race nodes failed, err: %w", errors.Join(errs...))
+}
+
+func (cluster *RaceCluster) winnerKey() string {
+	if cluster.WinnerKey != "" {
+		return cluster.WinnerKey
+	}
+
+	return cluster.Name() + "_winner"
+}
+
+// raceState decides the winner, writes of sub nodes are blocked while deciding and dropped after that.
+type raceState struct {
+	ogcore.State
+
+	winner string
+
+	sync.RWMutex
+}
+
+func (state *raceState) decide(winner string) bool {
+	state.Lock()
+	defer state.Unlock()
+
+	if state.winner != "" {
+		return false
+	}
+
+	state.winner = winner
+
+	return true
+}
+
+type raceMemberState struct {
+	race *raceState
+}
+
+func (state *raceMemberState) Get(key any) (any, bool) {
+	return state.race.State.Get(key)
+}
+
+func (state *raceMemberState) Set(key any, val any) {
+	state.race.RLock()
+	defer state.race.RUnlock()
+
+	if state.race.winner == "" {
+		state.race.State.Set(key, val)
+	}
+}
+
+func (state *raceMemberState) Update(key any, updateFunc func(val any) any) {
+	state.race.RLock()
+	defer state.race.RUnlock()
+
+	if state.race.winner == "" {
+		state.race.State.Update(key, updateFunc)
+	}
+}
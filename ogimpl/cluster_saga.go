@@ -0,0 +1,231 @@
+package ogimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var SagaClusterFactory = func() ogcore.Node {
+	return &SagaCluster{}
+}
+
+var ErrCompensationFailed = errors.New("saga compensation failed")
+
+const (
+	SagaRunning      = "running"
+	SagaCompensating = "compensating"
+	SagaCompleted    = "completed"
+	SagaCompensated  = "compensated"
+)
+
+// SagaProgress is progress of saga cluster kept in state, it's json serializable so that it's recorded by journal.
+type SagaProgress struct {
+	Status string
+
+	// Completed steps in order of completion.
+	Completed []string
+	// Compensated steps, whose compensations succeeded.
+	Compensated []string `json:",omitempty"`
+
+	FailedStep string `json:",omitempty"`
+	Err        string `json:",omitempty"`
+}
+
+// SagaCluster runs its steps one by one, if a step fails, compensations of completed steps are run in reverse order.
+//
+// Sub nodes which are compensations of others in Compensations are not run as steps, steps without compensation
+// are skipped when compensating. Compensations run with ctx even if it's canceled, limited by CompensationTimeout.
+// All compensations are tried, errors of them are collected into the returned error.
+//
+// Progress is set in state by ProgressKey as SagaProgress, which defaults to cluster name + "_saga".
+// If the cluster runs again on state with unfinished progress, e.g. resumed from journal, completed steps are
+// skipped, or compensations which have not succeeded are retried.
+type SagaCluster struct {
+	ograph.BaseCluster
+	*slog.Logger
+
+	// Compensations maps step names to names of their compensations.
+	Compensations map[string]string
+
+	CompensationTimeout time.Duration
+
+	ProgressKey string
+}
+
+func (cluster *SagaCluster) Run(ctx context.Context, state ogcore.State) error {
+	if cluster.Logger == nil {
+		cluster.Logger = slog.Default()
+	}
+
+	key := cluster.ProgressKey
+	if key == "" {
+		key = cluster.Name() + "_saga"
+	}
+
+	progress, err := loadSagaProgress(state, key)
+	if err != nil {
+		return fmt.Errorf("can't load progress of saga cluster (%s), err: %w", cluster.Name(), err)
+	}
+
+	if progress.Status != SagaRunning && progress.Status != SagaCompensating {
+		progress = SagaProgress{Status: SagaRunning}
+		state.Set(key, progress)
+	}
+
+	// original error of the failed step in this run, only its message is kept in progress
+	var failedErr error
+
+	if progress.Status == SagaRunning {
+		for _, node := range cluster.steps() {
+			step := nodeNameOf(node)
+
+			if slices.Contains(progress.Completed, step) {
+				continue
+			}
+
+			if err := node.Run(ctx, state); err != nil {
+				cluster.Warn("saga step failed", "SagaCluster", cluster.Name(), "SagaStep", step, "Error", err)
+
+				progress.Status, progress.FailedStep, progress.Err = SagaCompensating, step, err.Error()
+				state.Set(key, progress)
+
+				failedErr = err
+
+				break
+			}
+
+			progress.Completed = append(slices.Clone(progress.Completed), step)
+			state.Set(key, progress)
+		}
+
+		if progress.Status == SagaRunning {
+			progress.Status = SagaCompleted
+			state.Set(key, progress)
+
+			cluster.Info("saga cluster finish", "Steps", progress.Completed)
+			return nil
+		}
+	}
+
+	compensationErrs := cluster.compensate(ctx, state, key, &progress)
+
+	var stepErr error
+
+	if failedErr != nil {
+		stepErr = fmt.Errorf("saga step (%s) failed, err: %w", progress.FailedStep, failedErr)
+	} else {
+		stepErr = fmt.Errorf("saga step (%s) failed, err: %s", progress.FailedStep, progress.Err)
+	}
+
+	if len(compensationErrs) > 0 {
+		return errors.Join(stepErr, fmt.Errorf("%w, err: %w", ErrCompensationFailed, errors.Join(compensationErrs...)))
+	}
+
+	return stepErr
+}
+
+// steps returns sub nodes which are not compensations, in declared order.
+func (cluster *SagaCluster) steps() []ogcore.Node {
+	steps := make([]ogcore.Node, 0, len(cluster.Group))
+
+	for _, node := range cluster.Group {
+		isCompensation := false
+
+		for _, compensation := range cluster.Compensations {
+			if compensation == nodeNameOf(node) {
+				isCompensation = true
+			}
+		}
+
+		if !isCompensation {
+			steps = append(steps, node)
+		}
+	}
+
+	return steps
+}
+
+func (cluster *SagaCluster) compensate(ctx context.Context, state ogcore.State, key string, progress *SagaProgress) []error {
+	ctx = context.WithoutCancel(ctx)
+
+	if cluster.CompensationTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, cluster.CompensationTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+
+	for i := len(progress.Completed) - 1; i >= 0; i-- {
+		step := progress.Completed[i]
+
+		if slices.Contains(progress.Compensated, step) {
+			continue
+		}
+
+		compensationName, ok := cluster.Compensations[step]
+		if !ok {
+			continue
+		}
+
+		compensation, ok := cluster.NodeMap[compensationName]
+		if !ok {
+			errs = append(errs, fmt.Errorf("compensation (%s) of step (%s) not found", compensationName, step))
+			continue
+		}
+
+		if err := compensation.Run(ctx, state); err != nil {
+			cluster.Warn("saga compensation failed",
+				"SagaCluster", cluster.Name(), "SagaStep", step, "Compensation", compensationName, "Error", err)
+
+			errs = append(errs, fmt.Errorf("compensation (%s) of step (%s) failed, err: %w", compensationName, step, err))
+			continue
+		}
+
+		progress.Compensated = append(slices.Clone(progress.Compensated), step)
+		state.Set(key, *progress)
+	}
+
+	if len(errs) == 0 {
+		progress.Status = SagaCompensated
+		state.Set(key, *progress)
+
+		cluster.Info("saga cluster compensated", "FailedStep", progress.FailedStep, "Compensated", progress.Compensated)
+	}
+
+	return errs
+}
+
+// loadSagaProgress loads progress from state, progress restored from journal is decoded from generic json value.
+func loadSagaProgress(state ogcore.State, key string) (SagaProgress, error) {
+	val, _ := state.Get(key)
+
+	switch progress := val.(type) {
+	case nil:
+		return SagaProgress{}, nil
+	case SagaProgress:
+		return progress, nil
+	case *SagaProgress:
+		return *progress, nil
+	}
+
+	var progress SagaProgress
+
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return progress, err
+	}
+
+	err = json.Unmarshal(raw, &progress)
+
+	return progress, err
+}
@@ -9,14 +9,22 @@ const (
 	Choose   = "Choose"
 	Parallel = "Parallel"
 	Race     = "Race"
+	Fallback = "Fallback"
+	Hedge    = "Hedge"
+	Quorum   = "Quorum"
+	Saga     = "Saga"
 
-	Async     = "Async"
-	Condition = "Condition"
-	Loop      = "Loop"
-	Retry     = "Retry"
-	Silent    = "Silent"
-	Timeout   = "Timeout"
-	Trace     = "Trace"
-	Delay     = "Delay"
-	Debug     = "Debug"
+	Async          = "Async"
+	Condition      = "Condition"
+	Loop           = "Loop"
+	Retry          = "Retry"
+	Silent         = "Silent"
+	Timeout        = "Timeout"
+	Trace          = "Trace"
+	Delay          = "Delay"
+	Debug          = "Debug"
+	RateLimit      = "RateLimit"
+	CircuitBreaker = "CircuitBreaker"
+	Batch          = "Batch"
+	SingleFlight   = "SingleFlight"
 )
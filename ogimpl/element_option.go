@@ -1,6 +1,7 @@
 package ogimpl
 
 import (
+	"maps"
 	"time"
 
 	"github.com/symphony09/ograph"
@@ -36,6 +37,45 @@ func RetryOp(n int) ograph.ElementOption {
 	}
 }
 
+// RateLimitOp limits rate of element by the bucket of key, mode is RateLimitWait or RateLimitFailFast.
+func RateLimitOp(key string, rate float64, burst int, mode string) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.Wrap(RateLimit).
+			Params("LimiterKey", key).
+			Params("Rate", rate).
+			Params("Burst", burst).
+			Params("Mode", mode)
+	}
+}
+
+// CircuitBreakerOp opens breaker of key for openDuration after failureThreshold failures in a minute.
+func CircuitBreakerOp(key string, failureThreshold int, openDuration time.Duration) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.Wrap(CircuitBreaker).
+			Params("BreakerKey", key).
+			Params("FailureThreshold", failureThreshold).
+			Params("OpenDuration", openDuration)
+	}
+}
+
+// BatchOp runs element in batches of at most maxSize across runs, waiting at most maxWait for a batch.
+// Batches are shared across pipelines by key, or by runs of the same pipeline only if key is empty.
+func BatchOp(key string, maxSize int, maxWait time.Duration) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.Wrap(Batch).
+			Params("BatchKey", key).
+			Params("MaxBatchSize", maxSize).
+			Params("MaxWait", maxWait)
+	}
+}
+
+// SingleFlightOp lets only one execution of element proceed for concurrent runs of the pipeline with the same key of expr.
+func SingleFlightOp(expr string) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.Wrap(SingleFlight).Params("KeyExpr", expr)
+	}
+}
+
 func ConditionOp(expr string) ograph.ElementOption {
 	return func(e *ograph.Element) {
 		e.Wrap(Condition).Params("ConditionExpr", expr)
@@ -48,6 +88,55 @@ func ChooseOp(expr string, candidates ...*ograph.Element) ograph.ElementOption {
 	}
 }
 
+// FallbackOp runs primary in element, and runs fallbacks in order if it fails.
+func FallbackOp(primary *ograph.Element, fallbacks ...*ograph.Element) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.UseFactory(Fallback, append([]*ograph.Element{primary}, fallbacks...)...)
+	}
+}
+
+// HedgeOp runs members in element, the next member is started if previous ones have not finished after delay.
+func HedgeOp(delay time.Duration, members ...*ograph.Element) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.UseFactory(Hedge, members...).Params("HedgeDelay", delay)
+	}
+}
+
+// QuorumOp runs members in element at once, it succeeds when required of them succeed.
+func QuorumOp(required int, members ...*ograph.Element) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.UseFactory(Quorum, members...).Params("Required", required)
+	}
+}
+
+// SwitchOp runs candidate whose name equals result of expr, or defaultCase if none matches.
+func SwitchOp(expr string, defaultCase string, candidates ...*ograph.Element) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.UseFactory(Choose, candidates...).Params("SwitchExpr", expr).Params("Default", defaultCase)
+	}
+}
+
+// SagaStepOp adds step with its compensation to saga element, compensation can be nil.
+func SagaStepOp(step *ograph.Element, compensation *ograph.Element) ograph.ElementOption {
+	return func(e *ograph.Element) {
+		e.UseFactory(Saga, step)
+
+		if compensation != nil {
+			e.UseFactory(Saga, compensation)
+
+			compensations, _ := e.ParamsMap["Compensations"].(map[string]string)
+			compensations = maps.Clone(compensations)
+
+			if compensations == nil {
+				compensations = make(map[string]string)
+			}
+
+			compensations[step.Name] = compensation.Name
+			e.Params("Compensations", compensations)
+		}
+	}
+}
+
 func AssertOp(expr string) ograph.ElementOption {
 	return func(e *ograph.Element) {
 		e.UseFactory(Assert).Params("AssertExpr", expr)
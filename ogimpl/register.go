@@ -13,6 +13,10 @@ func init() {
 	global.Factories.Add(Choose, ChooseClusterFactory)
 	global.Factories.Add(Parallel, ParallelClusterFactory)
 	global.Factories.Add(Race, RaceClusterFactory)
+	global.Factories.Add(Fallback, FallbackClusterFactory)
+	global.Factories.Add(Hedge, HedgeClusterFactory)
+	global.Factories.Add(Quorum, QuorumClusterFactory)
+	global.Factories.Add(Saga, SagaClusterFactory)
 
 	global.Factories.Add(Async, AsyncWrapperFactory)
 	global.Factories.Add(Condition, ConditionWrapperFactory)
@@ -23,4 +27,8 @@ func init() {
 	global.Factories.Add(Trace, TraceWrapperFactory)
 	global.Factories.Add(Delay, DelayWrapperFactory)
 	global.Factories.Add(Debug, DebugWrapperFactory)
+	global.Factories.Add(RateLimit, RateLimitWrapperFactory)
+	global.Factories.Add(CircuitBreaker, CircuitBreakerWrapperFactory)
+	global.Factories.Add(Batch, BatchWrapperFactory)
+	global.Factories.Add(SingleFlight, SingleFlightWrapperFactory)
 }
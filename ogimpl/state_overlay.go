@@ -43,10 +43,28 @@ func (state *OverlayState) Update(key any, updateFunc func(val any) any) {
 	}
 }
 
+// Sync commits writes in upper layer into lower state, atomically if lower state implements ogcore.BatchSetter.
 func (state *OverlayState) Sync() {
-	for k, v := range state.Upper {
-		state.Lower.Set(k, v)
-		delete(state.Upper, k)
+	state.Lock()
+	defer state.Unlock()
+
+	if batchSetter, ok := state.Lower.(ogcore.BatchSetter); ok {
+		batchSetter.SetBatch(state.Upper)
+	} else {
+		for k, v := range state.Upper {
+			state.Lower.Set(k, v)
+		}
+	}
+
+	clear(state.Upper)
+}
+
+func (state *OverlayState) SetBatch(vals map[any]any) {
+	state.Lock()
+	defer state.Unlock()
+
+	for k, v := range vals {
+		state.Upper[k] = v
 	}
 }
 
@@ -57,3 +57,34 @@ func TestGuardState(t *testing.T) {
 		t.Error(v)
 	}
 }
+
+func TestOverlayState(t *testing.T) {
+	state := ograph.NewState()
+	state.Set("a", 0)
+
+	overlayState := ogimpl.NewOverlayState(state)
+	overlayState.Set("a", 1)
+	overlayState.Update("b", func(val any) any { return 2 })
+
+	if v, _ := state.Get("a"); v != 0 {
+		t.Error(v)
+	}
+
+	if v, _ := overlayState.Get("a"); v != 1 {
+		t.Error(v)
+	}
+
+	overlayState.Sync()
+
+	if v, _ := state.Get("a"); v != 1 {
+		t.Error(v)
+	}
+
+	if v, _ := state.Get("b"); v != 2 {
+		t.Error(v)
+	}
+
+	if len(overlayState.Upper) != 0 {
+		t.Error(overlayState.Upper)
+	}
+}
@@ -0,0 +1,365 @@
+package ogimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var BatchWrapperFactory = func() ogcore.Node {
+	return &BatchWrapper{}
+}
+
+var ErrBatchMismatch = errors.New("batch wrapper mismatches batcher")
+
+// BatchResult is result of an item in batch.
+type BatchResult struct {
+	Value any
+	Err   error
+}
+
+// BatchRunner is implemented by nodes which can run for states of multiple runs at once.
+// It returns errors for each state, or nil if all succeeded.
+type BatchRunner interface {
+	RunBatch(ctx context.Context, states []ogcore.State) []error
+}
+
+// BatchWrapper collects invocations of wrapped node across concurrent runs, and runs them as a batch
+// when there are MaxBatchSize of them, or MaxWait passed since the first one.
+//
+// If BatchFn is set, items are loaded from state of each run by ItemKey, and results are set by ResultKey,
+// otherwise wrapped node should implement BatchRunner.
+//
+// Without BatchKey, batches are shared by runs of the same pipeline only. Wrappers with the same BatchKey
+// share one batcher across pipelines and runs, the batcher is created by the first wrapper which uses it,
+// with its MaxBatchSize and MaxWait, and removed when no run uses it. Wrappers of a batcher should have
+// the same BatchFn, ItemKey, ResultKey and type of wrapped node, otherwise they fail with ErrBatchMismatch.
+// The batch runs on the wrapped node of a run in it, with ctx of that run, but not canceled by it.
+// Runs whose ctx is done return at once if their batch has not started, their items are dropped,
+// otherwise they wait for the batch.
+type BatchWrapper struct {
+	ograph.BaseEventWrapper
+
+	BatchKey string
+
+	// MaxBatchSize is 10 by default.
+	MaxBatchSize int
+	// MaxWait is 10 milliseconds by default.
+	MaxWait time.Duration
+
+	BatchFn   func(ctx context.Context, items []any) ([]BatchResult, error)
+	ItemKey   string
+	ResultKey string
+}
+
+func (wrapper *BatchWrapper) Run(ctx context.Context, state ogcore.State) error {
+	_, isBatchRunner := wrapper.Node.(BatchRunner)
+
+	if wrapper.BatchFn == nil && !isBatchRunner {
+		return fmt.Errorf("batch function of %s is not set and wrapped node is not BatchRunner", wrapper.Name())
+	}
+
+	key := wrapper.batcherKey()
+
+	b, err := batchers.acquire(key, wrapper)
+	if err != nil {
+		return err
+	}
+
+	defer batchers.release(key, b)
+
+	call := &batchCall{ctx: ctx, state: state, run: wrapper.runBatch, done: make(chan error, 1)}
+
+	b.submit(call)
+
+	select {
+	case err := <-call.done:
+		return err
+	case <-ctx.Done():
+		if call.cancel() {
+			return ctx.Err()
+		}
+
+		// the batch has started on wrapped node of this run, wait for it
+		return <-call.done
+	}
+}
+
+// batcherKey returns key of batcher in registry, batchers without BatchKey are scoped to event bus of pipeline.
+func (wrapper *BatchWrapper) batcherKey() batcherKey {
+	if wrapper.BatchKey != "" {
+		return batcherKey{name: wrapper.BatchKey}
+	}
+
+	if wrapper.EventBus == nil {
+		return batcherKey{scope: wrapper, name: wrapper.Name()}
+	}
+
+	return batcherKey{scope: wrapper.EventBus, name: wrapper.Name()}
+}
+
+func (wrapper *BatchWrapper) signature() batchSignature {
+	sig := batchSignature{itemKey: wrapper.ItemKey, resultKey: wrapper.ResultKey}
+
+	if wrapper.BatchFn != nil {
+		sig.fn = reflect.ValueOf(wrapper.BatchFn).Pointer()
+	} else {
+		sig.node = reflect.TypeOf(wrapper.Node)
+	}
+
+	return sig
+}
+
+func (wrapper *BatchWrapper) runBatch(ctx context.Context, states []ogcore.State) (errs []error) {
+	defer func() {
+		if p := recover(); p != nil {
+			errs = fillErrors(len(states), fmt.Errorf("batch panic, info: %v", p))
+		}
+	}()
+
+	if wrapper.BatchFn == nil {
+		return wrapper.Node.(BatchRunner).RunBatch(ctx, states)
+	}
+
+	items := make([]any, len(states))
+
+	for i, state := range states {
+		items[i], _ = state.Get(wrapper.ItemKey)
+	}
+
+	results, err := wrapper.BatchFn(ctx, items)
+	if err != nil {
+		return fillErrors(len(states), err)
+	}
+
+	if len(results) != len(states) {
+		return fillErrors(len(states), fmt.Errorf("batch function returned %d results for %d items", len(results), len(states)))
+	}
+
+	errs = make([]error, len(states))
+
+	for i, result := range results {
+		if result.Err != nil {
+			errs[i] = result.Err
+		} else if wrapper.ResultKey != "" {
+			states[i].Set(wrapper.ResultKey, result.Value)
+		}
+	}
+
+	return errs
+}
+
+func fillErrors(n int, err error) []error {
+	errs := make([]error, n)
+
+	for i := range errs {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+type batchCall struct {
+	ctx   context.Context
+	state ogcore.State
+	run   func(ctx context.Context, states []ogcore.State) []error
+	done  chan error
+
+	// claimed is set when call is canceled or its batch starts, canceled calls are dropped from batch.
+	claimed bool
+	sync.Mutex
+}
+
+// cancel reports whether call is dropped, it's false if its batch has started.
+func (call *batchCall) cancel() bool {
+	call.Lock()
+	defer call.Unlock()
+
+	if call.claimed {
+		return false
+	}
+
+	call.claimed = true
+
+	return true
+}
+
+// start reports whether call is still wanted, it can't be canceled after started.
+func (call *batchCall) start() bool {
+	call.Lock()
+	defer call.Unlock()
+
+	if call.claimed {
+		return false
+	}
+
+	call.claimed = true
+
+	return true
+}
+
+type batcher struct {
+	maxSize int
+	maxWait time.Duration
+
+	// signature of wrapper which creates batcher, any wrapper of batcher may run the batch.
+	signature batchSignature
+
+	// refs is number of runs using batcher, it's guarded by lock of registry.
+	refs int
+
+	pending []*batchCall
+	timer   *time.Timer
+
+	// generation is increased on every take, so that a stale timer does not take calls of next batch.
+	generation int
+
+	sync.Mutex
+}
+
+func (b *batcher) submit(call *batchCall) {
+	b.Lock()
+
+	b.pending = append(b.pending, call)
+
+	if len(b.pending) < b.maxSize {
+		if len(b.pending) == 1 {
+			generation := b.generation
+
+			b.timer = time.AfterFunc(b.maxWait, func() {
+				b.Lock()
+
+				if b.generation != generation {
+					b.Unlock()
+					return
+				}
+
+				calls := b.take()
+				b.Unlock()
+
+				flushBatch(calls)
+			})
+		}
+
+		b.Unlock()
+		return
+	}
+
+	calls := b.take()
+	b.Unlock()
+
+	go flushBatch(calls)
+}
+
+// take returns pending calls and resets the batcher, it must be called with lock held.
+func (b *batcher) take() []*batchCall {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	calls := b.pending
+	b.pending = nil
+	b.generation++
+
+	return calls
+}
+
+// flushBatch runs started calls on wrapped node of the first one, runs of started calls wait for the batch,
+// so the node is not used by others meanwhile.
+func flushBatch(calls []*batchCall) {
+	started := make([]*batchCall, 0, len(calls))
+
+	for _, call := range calls {
+		if call.start() {
+			started = append(started, call)
+		}
+	}
+
+	if len(started) == 0 {
+		return
+	}
+
+	states := make([]ogcore.State, len(started))
+
+	for i, call := range started {
+		states[i] = call.state
+	}
+
+	errs := started[0].run(context.WithoutCancel(started[0].ctx), states)
+
+	for i, call := range started {
+		if i < len(errs) {
+			call.done <- errs[i]
+		} else if errs != nil {
+			call.done <- errors.New("no result of batch item")
+		} else {
+			call.done <- nil
+		}
+	}
+}
+
+type batchSignature struct {
+	fn        uintptr
+	node      reflect.Type
+	itemKey   string
+	resultKey string
+}
+
+type batcherKey struct {
+	scope any
+	name  string
+}
+
+// batcherRegistry keeps batchers in use, a batcher is removed when the last run using it returns.
+type batcherRegistry struct {
+	batchers map[batcherKey]*batcher
+
+	sync.Mutex
+}
+
+var batchers = &batcherRegistry{batchers: make(map[batcherKey]*batcher)}
+
+func (registry *batcherRegistry) acquire(key batcherKey, wrapper *BatchWrapper) (*batcher, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	signature := wrapper.signature()
+
+	b := registry.batchers[key]
+
+	if b == nil {
+		b = &batcher{maxSize: wrapper.MaxBatchSize, maxWait: wrapper.MaxWait, signature: signature}
+
+		if b.maxSize <= 0 {
+			b.maxSize = 10
+		}
+
+		if b.maxWait <= 0 {
+			b.maxWait = 10 * time.Millisecond
+		}
+
+		registry.batchers[key] = b
+	} else if b.signature != signature {
+		return nil, fmt.Errorf("%w, wrapper: %s, batcher: %s", ErrBatchMismatch, wrapper.Name(), key.name)
+	}
+
+	b.refs++
+
+	return b, nil
+}
+
+func (registry *batcherRegistry) release(key batcherKey, b *batcher) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if b.refs--; b.refs == 0 && registry.batchers[key] == b {
+		delete(registry.batchers, key)
+	}
+}
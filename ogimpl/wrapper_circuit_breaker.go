@@ -0,0 +1,365 @@
+package ogimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var CircuitBreakerWrapperFactory = func() ogcore.Node {
+	return &CircuitBreakerWrapper{}
+}
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// Events emitted on pipeline event bus when circuit breaker changes its state, payload is *CircuitTransition.
+const (
+	EventCircuitClosed   = "circuit." + CircuitClosed
+	EventCircuitOpen     = "circuit." + CircuitOpen
+	EventCircuitHalfOpen = "circuit." + CircuitHalfOpen
+)
+
+// CircuitOpenError is returned when circuit breaker short-circuits the node, it matches ErrCircuitOpen.
+type CircuitOpenError struct {
+	Key string
+
+	// OpenUntil is when the breaker turns half-open, it's zero if the breaker is half-open and probes are full.
+	OpenUntil time.Time
+}
+
+func (err *CircuitOpenError) Error() string {
+	if err.OpenUntil.IsZero() {
+		return fmt.Sprintf("%v, breaker: %s, probing", ErrCircuitOpen, err.Key)
+	}
+
+	return fmt.Sprintf("%v, breaker: %s, open until %s", ErrCircuitOpen, err.Key, err.OpenUntil.Format(time.RFC3339Nano))
+}
+
+func (err *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitTransition is payload of circuit events.
+type CircuitTransition struct {
+	Key  string
+	Node string
+	From string
+	To   string
+
+	// Err is the failure which opened the breaker.
+	Err error
+}
+
+// CircuitBreakerWrapper stops running wrapped node for a while when it keeps failing.
+//
+// When closed, the breaker opens if failures in Window reach FailureThreshold, or if FailureRatio is set,
+// failures / runs in Window reach FailureRatio with at least MinRuns runs.
+// When open, runs fail with CircuitOpenError until OpenDuration passed, then the breaker turns half-open.
+// When half-open, at most HalfOpenProbes runs are let through, it closes if all of them succeed and opens on any failure.
+//
+// Wrappers with the same BreakerKey share one breaker across pipelines and runs, they should use the same settings.
+// Without BreakerKey, the breaker is shared by runs of the element in its pipeline only.
+// Failures caused by canceled ctx are not counted.
+type CircuitBreakerWrapper struct {
+	ograph.BaseEventWrapper
+
+	BreakerKey string
+
+	// FailureThreshold is 5 by default.
+	FailureThreshold int
+	FailureRatio     float64
+	MinRuns          int
+
+	// Window is 1 minute by default.
+	Window time.Duration
+
+	// OpenDuration is 30 seconds by default.
+	OpenDuration time.Duration
+
+	// HalfOpenProbes is 1 by default.
+	HalfOpenProbes int
+}
+
+func (wrapper *CircuitBreakerWrapper) Run(ctx context.Context, state ogcore.State) error {
+	breaker, err := circuitBreakers.get(wrapper.breakerKey(), wrapper.settings())
+	if err != nil {
+		return err
+	}
+
+	generation, transition, err := breaker.allow(time.Now())
+	if err != nil {
+		return err
+	} else if transition != nil {
+		wrapper.emit(state, transition)
+	}
+
+	err = wrapper.Node.Run(ctx, state)
+
+	if err != nil && ctx.Err() != nil {
+		breaker.release(generation)
+		return err
+	}
+
+	if transition := breaker.done(generation, time.Now(), err); transition != nil {
+		wrapper.emit(state, transition)
+	}
+
+	return err
+}
+
+// breakerKey returns key of breaker in registry, breakers without BreakerKey are scoped to event bus of pipeline.
+func (wrapper *CircuitBreakerWrapper) breakerKey() breakerKey {
+	if wrapper.BreakerKey != "" {
+		return breakerKey{name: wrapper.BreakerKey}
+	}
+
+	if wrapper.EventBus == nil {
+		return breakerKey{scope: wrapper, name: wrapper.Name()}
+	}
+
+	return breakerKey{scope: wrapper.EventBus, name: wrapper.Name()}
+}
+
+// settings returns settings of breaker with defaults applied.
+func (wrapper *CircuitBreakerWrapper) settings() breakerSettings {
+	settings := breakerSettings{
+		failureThreshold: wrapper.FailureThreshold,
+		failureRatio:     wrapper.FailureRatio,
+		minRuns:          max(wrapper.MinRuns, 1),
+		window:           wrapper.Window,
+		openDuration:     wrapper.OpenDuration,
+		halfOpenProbes:   wrapper.HalfOpenProbes,
+	}
+
+	if settings.failureThreshold <= 0 {
+		settings.failureThreshold = 5
+	}
+
+	if settings.window <= 0 {
+		settings.window = time.Minute
+	}
+
+	if settings.openDuration <= 0 {
+		settings.openDuration = 30 * time.Second
+	}
+
+	if settings.halfOpenProbes <= 0 {
+		settings.halfOpenProbes = 1
+	}
+
+	return settings
+}
+
+func (wrapper *CircuitBreakerWrapper) emit(state ogcore.State, transition *CircuitTransition) {
+	transition.Node = wrapper.Name()
+
+	wrapper.EmitPayload("circuit."+transition.To, state, transition)
+}
+
+type breakerSettings struct {
+	failureThreshold int
+	failureRatio     float64
+	minRuns          int
+	window           time.Duration
+	openDuration     time.Duration
+	halfOpenProbes   int
+}
+
+type circuitBreaker struct {
+	key string
+
+	breakerSettings
+
+	state     string
+	outcomes  []circuitOutcome
+	openUntil time.Time
+	probing   int
+	probed    int
+
+	// generation is increased on every transition, results of runs allowed in previous generations are ignored.
+	generation int
+
+	sync.Mutex
+}
+
+type circuitOutcome struct {
+	time   time.Time
+	failed bool
+}
+
+// allow checks whether a run can go on, it returns transition if the breaker turns half-open.
+func (breaker *circuitBreaker) allow(now time.Time) (int, *CircuitTransition, error) {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	var transition *CircuitTransition
+
+	if breaker.state == CircuitOpen {
+		if now.Before(breaker.openUntil) {
+			return 0, nil, &CircuitOpenError{Key: breaker.key, OpenUntil: breaker.openUntil}
+		}
+
+		transition = breaker.turn(CircuitHalfOpen, nil)
+	}
+
+	if breaker.state == CircuitHalfOpen {
+		if breaker.probing+breaker.probed >= breaker.halfOpenProbes {
+			return 0, nil, &CircuitOpenError{Key: breaker.key}
+		}
+
+		breaker.probing++
+	}
+
+	return breaker.generation, transition, nil
+}
+
+// release gives back the probe of a run which is not counted.
+func (breaker *circuitBreaker) release(generation int) {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	if breaker.state == CircuitHalfOpen && breaker.generation == generation {
+		breaker.probing--
+	}
+}
+
+// done records result of a run, it returns transition if the breaker opens or closes.
+func (breaker *circuitBreaker) done(generation int, now time.Time, err error) *CircuitTransition {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	if generation != breaker.generation {
+		return nil
+	}
+
+	switch breaker.state {
+	case CircuitHalfOpen:
+		breaker.probing--
+
+		if err != nil {
+			breaker.openUntil = now.Add(breaker.openDuration)
+			return breaker.turn(CircuitOpen, err)
+		}
+
+		if breaker.probed++; breaker.probed >= breaker.halfOpenProbes {
+			return breaker.turn(CircuitClosed, nil)
+		}
+	case CircuitClosed:
+		breaker.outcomes = append(breaker.outcomes, circuitOutcome{time: now, failed: err != nil})
+
+		// drop outcomes out of window
+		i := 0
+		for i < len(breaker.outcomes) && now.Sub(breaker.outcomes[i].time) > breaker.window {
+			i++
+		}
+		breaker.outcomes = breaker.outcomes[i:]
+
+		if err != nil && breaker.tripped() {
+			breaker.openUntil = now.Add(breaker.openDuration)
+			return breaker.turn(CircuitOpen, err)
+		}
+	}
+
+	return nil
+}
+
+func (breaker *circuitBreaker) tripped() bool {
+	failures := 0
+
+	for _, outcome := range breaker.outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+
+	if breaker.failureRatio > 0 {
+		runs := len(breaker.outcomes)
+		return runs >= breaker.minRuns && float64(failures)/float64(runs) >= breaker.failureRatio
+	}
+
+	return failures >= breaker.failureThreshold
+}
+
+// turn changes state of the breaker, it must be called with lock held.
+func (breaker *circuitBreaker) turn(to string, err error) *CircuitTransition {
+	transition := &CircuitTransition{Key: breaker.key, From: breaker.state, To: to, Err: err}
+
+	breaker.state = to
+	breaker.generation++
+	breaker.outcomes = nil
+	breaker.probing, breaker.probed = 0, 0
+
+	return transition
+}
+
+// idle reports whether the breaker is closed without outcomes in window, which is the same as a new breaker.
+func (breaker *circuitBreaker) idle(now time.Time) bool {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	if breaker.state != CircuitClosed {
+		return false
+	}
+
+	for _, outcome := range breaker.outcomes {
+		if now.Sub(outcome.time) <= breaker.window {
+			return false
+		}
+	}
+
+	return true
+}
+
+type breakerKey struct {
+	scope any
+	name  string
+}
+
+// breakerRegistry never removes breakers with BreakerKey, they may be used by pipelines at any time.
+// Breakers scoped to pipelines are removed once they are idle, so that they don't outlive their pipelines.
+type breakerRegistry struct {
+	breakers map[breakerKey]*circuitBreaker
+
+	sync.Mutex
+}
+
+var circuitBreakers = &breakerRegistry{breakers: make(map[breakerKey]*circuitBreaker)}
+
+func (registry *breakerRegistry) get(key breakerKey, settings breakerSettings) (*circuitBreaker, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	breaker := registry.breakers[key]
+
+	if breaker == nil {
+		registry.sweep(time.Now())
+
+		breaker = &circuitBreaker{key: key.name, breakerSettings: settings, state: CircuitClosed}
+
+		registry.breakers[key] = breaker
+	} else if breaker.breakerSettings != settings {
+		return nil, fmt.Errorf("breaker %s is used with other settings", key.name)
+	}
+
+	return breaker, nil
+}
+
+// sweep removes idle breakers scoped to pipelines, it must be called with lock held.
+func (registry *breakerRegistry) sweep(now time.Time) {
+	for key, breaker := range registry.breakers {
+		if key.scope != nil && breaker.idle(now) {
+			delete(registry.breakers, key)
+		}
+	}
+}
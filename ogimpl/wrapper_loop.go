@@ -2,13 +2,12 @@ package ogimpl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/expr-lang/expr"
-	"github.com/expr-lang/expr/ast"
-	"github.com/expr-lang/expr/parser"
 	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/internal"
 	"github.com/symphony09/ograph/ogcore"
 )
 
@@ -16,6 +15,31 @@ var LoopWrapperFactory = func() ogcore.Node {
 	return &LoopWrapper{LoopTimes: 1}
 }
 
+// Loop control errors which wrapped node can return, they can be wrapped.
+var (
+	// ErrBreak stops the loop without error.
+	ErrBreak = errors.New("loop break")
+	// ErrContinue goes on with the next iteration, result of the current iteration is not collected.
+	ErrContinue = errors.New("loop continue")
+)
+
+var ErrMaxIterations = errors.New("loop exceeds max iterations")
+
+type loopIterationKey struct{}
+
+// LoopIterationFrom returns index of current iteration of node wrapped by LoopWrapper, starting from 0.
+func LoopIterationFrom(ctx context.Context) int {
+	iteration, _ := ctx.Value(loopIterationKey{}).(int)
+	return iteration
+}
+
+// LoopWrapper runs wrapped node LoopTimes times, or while Condition is true, waiting LoopInterval between iterations.
+//
+// Condition loops fail with ErrMaxIterations if the condition is still true after MaxIterations iterations.
+// Index of current iteration is passed by ctx, see LoopIterationFrom, and set in state by IterationKey if it's set.
+// Condition is checked after waiting LoopInterval, so that it sees state after waiting.
+// If ResultsKey is set, value of ResultKey in state after each iteration is collected into a []any set by ResultsKey,
+// ResultKey is required then.
 type LoopWrapper struct {
 	ograph.BaseWrapper
 
@@ -24,6 +48,15 @@ type LoopWrapper struct {
 
 	ConditionExpr string
 	Condition     func(ctx context.Context, state ogcore.State) bool
+
+	// MaxIterations is 1000 by default.
+	MaxIterations int
+
+	IterationKey string
+	ResultKey    string
+	ResultsKey   string
+
+	condition func(ctx context.Context, state ogcore.State) (bool, error)
 }
 
 func (wrapper *LoopWrapper) Init(params map[string]any) error {
@@ -33,6 +66,12 @@ func (wrapper *LoopWrapper) Init(params map[string]any) error {
 		wrapper.LoopTimes = int(loopTimes2)
 	}
 
+	if maxIterations, ok := params["MaxIterations"].(int); ok {
+		wrapper.MaxIterations = maxIterations
+	} else if maxIterations2, ok := params["MaxIterations"].(float64); ok {
+		wrapper.MaxIterations = int(maxIterations2)
+	}
+
 	if loopInterval, ok := params["LoopInterval"].(time.Duration); ok {
 		wrapper.LoopInterval = loopInterval
 	} else if loopIntervalStr, ok := params["LoopInterval"].(string); ok {
@@ -43,73 +82,106 @@ func (wrapper *LoopWrapper) Init(params map[string]any) error {
 		wrapper.LoopInterval = loopInterval
 	}
 
+	wrapper.IterationKey, _ = params["IterationKey"].(string)
+	wrapper.ResultKey, _ = params["ResultKey"].(string)
+	wrapper.ResultsKey, _ = params["ResultsKey"].(string)
+
+	if wrapper.ResultsKey != "" && wrapper.ResultKey == "" {
+		return fmt.Errorf("result key is required to collect results into %s", wrapper.ResultsKey)
+	}
+
 	if fn, ok := params["Condition"].(func(ctx context.Context, state ogcore.State) bool); ok {
 		wrapper.Condition = fn
 	} else if exprStr, ok := params["ConditionExpr"].(string); ok {
 		wrapper.ConditionExpr = exprStr
 
-		program, err := expr.Compile(exprStr)
+		stateExpr, err := internal.CompileStateExpr(exprStr)
 		if err != nil {
 			return err
 		}
 
-		tree, err := parser.Parse(exprStr)
-		if err != nil {
-			return err
+		wrapper.condition = func(ctx context.Context, state ogcore.State) (bool, error) {
+			return stateExpr.RunBool(state, nil)
 		}
 
-		v := &Visitor{}
-		ast.Walk(&tree.Node, v)
-
-		wrapper.Condition = func(ctx context.Context, state ogcore.State) bool {
-			env := make(map[string]any)
-
-			for _, identifier := range v.Identifiers {
-				env[identifier], _ = state.Get(identifier)
-			}
+		return nil
+	}
 
-			output, err := expr.Run(program, env)
-			if err != nil {
-				panic(err)
-			}
+	return nil
+}
 
-			if ret, ok := output.(bool); ok {
-				return ret
-			}
+func (wrapper *LoopWrapper) Run(ctx context.Context, state ogcore.State) error {
+	condition := wrapper.condition
 
-			panic(fmt.Errorf("unknown result: %v", output))
+	if wrapper.Condition != nil {
+		condition = func(ctx context.Context, state ogcore.State) (bool, error) {
+			return wrapper.Condition(ctx, state), nil
 		}
+	}
 
-		return nil
+	if condition == nil && wrapper.LoopTimes < 0 {
+		wrapper.LoopTimes = 1
 	}
 
-	return nil
-}
+	maxIterations := wrapper.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 1000
+	}
 
-func (wrapper *LoopWrapper) Run(ctx context.Context, state ogcore.State) error {
-	if wrapper.Condition == nil {
-		if wrapper.LoopTimes < 0 {
-			wrapper.LoopTimes = 1
+	var results []any
+
+	for i := 0; ; i++ {
+		if condition == nil && i >= wrapper.LoopTimes {
+			break
 		}
 
-		for i := 0; i < wrapper.LoopTimes; i++ {
-			if err := wrapper.Node.Run(ctx, state); err != nil {
-				return err
-			}
+		// wait before checking condition, so that it's checked with state after waiting
+		if i > 0 && wrapper.LoopInterval > 0 {
+			timer := time.NewTimer(wrapper.LoopInterval)
 
-			time.Sleep(wrapper.LoopInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
 		}
 
-		return nil
-	} else {
-		for wrapper.Condition(ctx, state) {
-			if err := wrapper.Node.Run(ctx, state); err != nil {
-				return err
+		if condition != nil {
+			if ok, err := condition(ctx, state); err != nil {
+				return fmt.Errorf("check loop condition failed, err: %w", err)
+			} else if !ok {
+				break
+			}
+
+			if i >= maxIterations {
+				return fmt.Errorf("%w, max iterations: %d", ErrMaxIterations, maxIterations)
 			}
+		}
 
-			time.Sleep(wrapper.LoopInterval)
+		if wrapper.IterationKey != "" {
+			state.Set(wrapper.IterationKey, i)
 		}
 
-		return nil
+		err := wrapper.Node.Run(context.WithValue(ctx, loopIterationKey{}, i), state)
+
+		if errors.Is(err, ErrBreak) {
+			break
+		} else if errors.Is(err, ErrContinue) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if wrapper.ResultsKey != "" {
+			result, _ := state.Get(wrapper.ResultKey)
+			results = append(results, result)
+		}
+	}
+
+	if wrapper.ResultsKey != "" {
+		state.Set(wrapper.ResultsKey, results)
 	}
+
+	return nil
 }
@@ -0,0 +1,251 @@
+package ogimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var RateLimitWrapperFactory = func() ogcore.Node {
+	return &RateLimitWrapper{}
+}
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+const (
+	// RateLimitWait waits for token until ctx is done.
+	RateLimitWait = "Wait"
+	// RateLimitFailFast returns ErrRateLimited if there is no token.
+	RateLimitFailFast = "FailFast"
+)
+
+// RateLimitWrapper limits running rate of wrapped node by token bucket.
+// Wrappers with the same LimiterKey share one bucket across pipelines and runs, they should use the same Rate and Burst.
+// Without LimiterKey, the bucket is shared by runs of the element in its pipeline only.
+// Buckets with LimiterKey are kept for the lifetime of the process, so keys should come from a bounded set,
+// not from run data.
+type RateLimitWrapper struct {
+	ograph.BaseEventWrapper
+
+	// Rate is the number of tokens added to bucket per second.
+	Rate float64
+	// Burst is the capacity of bucket, 1 is used if it's not positive.
+	Burst      int
+	LimiterKey string
+	Mode       string
+}
+
+func (wrapper *RateLimitWrapper) Init(params map[string]any) error {
+	if rate, ok := params["Rate"].(float64); ok {
+		wrapper.Rate = rate
+	} else if rate2, ok := params["Rate"].(int); ok {
+		wrapper.Rate = float64(rate2)
+	}
+
+	if burst, ok := params["Burst"].(int); ok {
+		wrapper.Burst = burst
+	} else if burst2, ok := params["Burst"].(float64); ok {
+		wrapper.Burst = int(burst2)
+	}
+
+	wrapper.LimiterKey, _ = params["LimiterKey"].(string)
+	wrapper.Mode, _ = params["Mode"].(string)
+
+	return wrapper.validate()
+}
+
+func (wrapper *RateLimitWrapper) validate() error {
+	if wrapper.Rate <= 0 {
+		return fmt.Errorf("invalid rate %v of %s, it should be positive", wrapper.Rate, wrapper.Name())
+	}
+
+	switch wrapper.Mode {
+	case "", RateLimitWait, RateLimitFailFast:
+		return nil
+	default:
+		return fmt.Errorf("unknown rate limit mode %q of %s", wrapper.Mode, wrapper.Name())
+	}
+}
+
+// limiterKey returns key of bucket in registry, buckets without LimiterKey are scoped to event bus of pipeline.
+func (wrapper *RateLimitWrapper) limiterKey() limiterKey {
+	if wrapper.LimiterKey != "" {
+		return limiterKey{name: wrapper.LimiterKey}
+	}
+
+	if wrapper.EventBus == nil {
+		return limiterKey{scope: wrapper, name: wrapper.Name()}
+	}
+
+	return limiterKey{scope: wrapper.EventBus, name: wrapper.Name()}
+}
+
+func (wrapper *RateLimitWrapper) Run(ctx context.Context, state ogcore.State) error {
+	// singleton wrappers are not initialized
+	if err := wrapper.validate(); err != nil {
+		return err
+	}
+
+	key := wrapper.limiterKey()
+
+	bucket, err := rateLimiters.get(key, wrapper.Rate, max(wrapper.Burst, 1))
+	if err != nil {
+		return err
+	}
+
+	if wrapper.Mode == RateLimitFailFast {
+		if !bucket.take() {
+			return fmt.Errorf("%w, limiter: %s", ErrRateLimited, key.name)
+		}
+	} else if err := bucket.wait(ctx); err != nil {
+		return fmt.Errorf("%w, limiter: %s, error: %w", ErrRateLimited, key.name, err)
+	}
+
+	return wrapper.Node.Run(ctx, state)
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	sync.Mutex
+}
+
+// refill adds tokens for the time passed, it must be called with lock held.
+func (bucket *tokenBucket) refill(now time.Time) {
+	bucket.tokens = min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	bucket.last = now
+}
+
+func (bucket *tokenBucket) take() bool {
+	bucket.Lock()
+	defer bucket.Unlock()
+
+	bucket.refill(time.Now())
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// wait reserves a token and waits until it's available, the token is given back if ctx is done before.
+func (bucket *tokenBucket) wait(ctx context.Context) error {
+	bucket.Lock()
+
+	now := time.Now()
+	bucket.refill(now)
+
+	var delay time.Duration
+
+	if bucket.tokens < 1 {
+		delay = time.Duration((1 - bucket.tokens) / bucket.rate * float64(time.Second))
+
+		if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+			bucket.Unlock()
+			return fmt.Errorf("waiting %s would exceed deadline", delay)
+		}
+	}
+
+	bucket.tokens--
+
+	bucket.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.Lock()
+		bucket.refill(time.Now())
+		bucket.tokens = min(bucket.burst, bucket.tokens+1)
+		bucket.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// full reports whether bucket is full, which is the same as a new bucket, it must be called with lock held.
+func (bucket *tokenBucket) full(now time.Time) bool {
+	bucket.refill(now)
+	return bucket.tokens >= bucket.burst
+}
+
+type limiterKey struct {
+	scope any
+	name  string
+}
+
+// limiterRegistry never removes buckets with LimiterKey, they may be used by pipelines at any time.
+// Buckets scoped to pipelines are removed once they are full, so that they don't outlive their pipelines.
+type limiterRegistry struct {
+	buckets map[limiterKey]*tokenBucket
+
+	sync.Mutex
+}
+
+var rateLimiters = &limiterRegistry{buckets: make(map[limiterKey]*tokenBucket)}
+
+func (registry *limiterRegistry) get(key limiterKey, rate float64, burst int) (*tokenBucket, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	bucket := registry.buckets[key]
+
+	if bucket == nil {
+		now := time.Now()
+
+		registry.sweep(now)
+
+		bucket = &tokenBucket{
+			rate:   rate,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   now,
+		}
+
+		registry.buckets[key] = bucket
+	} else if bucket.rate != rate || bucket.burst != float64(burst) {
+		return nil, fmt.Errorf("limiter %s is used with rate %v and burst %v, got rate %v and burst %d",
+			key.name, bucket.rate, bucket.burst, rate, burst)
+	}
+
+	return bucket, nil
+}
+
+// sweep removes full buckets scoped to pipelines, it must be called with lock held.
+func (registry *limiterRegistry) sweep(now time.Time) {
+	for key, bucket := range registry.buckets {
+		if key.scope == nil {
+			continue
+		}
+
+		bucket.Lock()
+		full := bucket.full(now)
+		bucket.Unlock()
+
+		if full {
+			delete(registry.buckets, key)
+		}
+	}
+}
+
+func NewRateLimitWrapper(key string, rate float64, burst int) ogcore.Node {
+	return &RateLimitWrapper{LimiterKey: key, Rate: rate, Burst: burst}
+}
@@ -2,10 +2,15 @@ package ogimpl
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/internal"
 	"github.com/symphony09/ograph/ogcore"
 )
 
@@ -13,12 +18,51 @@ var RetryWrapperFactory = func() ogcore.Node {
 	return &RetryWrapper{MaxRetryTimes: 1}
 }
 
+type retryAttemptKey struct{}
+
+// RetryAttemptFrom returns the number of current attempt of node wrapped by RetryWrapper, starting from 1.
+// It returns 0 if the node is not wrapped by RetryWrapper.
+func RetryAttemptFrom(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryAttemptKey{}).(int)
+	return attempt
+}
+
+// IsErrorType reports whether any error in err's tree is of type T, it can be used as RetryIf.
+func IsErrorType[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
+
+// RetryWrapper runs wrapped node again when it fails.
+//
+// The delay before each retry starts from RetryDelay, and is multiplied by BackoffFactor after each retry
+// up to MaxRetryDelay. With Jitter in (0, 1], the delay is randomly reduced by at most Jitter of it.
+// Retries stop when MaxRetryTimes is reached, when the delay would exceed MaxElapsedTime in total, or when ctx is done.
+//
+// If any of RetryIf, RetryOn and RetryIfExpr is set, only errors matching one of them are retried.
+// RetryIfExpr is evaluated with state, err (error message) and attempt (the failed attempt).
 type RetryWrapper struct {
-	ograph.BaseWrapper
+	ograph.BaseEventWrapper
 	*slog.Logger
 
 	MaxRetryTimes int
 	RetryDelay    *time.Duration
+
+	BackoffFactor  float64
+	MaxRetryDelay  time.Duration
+	Jitter         float64
+	MaxElapsedTime time.Duration
+
+	RetryIf     func(err error) bool
+	RetryOn     []error
+	RetryIfExpr string
+
+	// AttemptStateKey is the state key to store number of current attempt, see also RetryAttemptFrom.
+	AttemptStateKey string
+
+	compileOnce sync.Once
+	retryIf     *internal.StateExpr
+	compileErr  error
 }
 
 func (wrapper *RetryWrapper) Run(ctx context.Context, state ogcore.State) error {
@@ -26,35 +70,112 @@ func (wrapper *RetryWrapper) Run(ctx context.Context, state ogcore.State) error
 		wrapper.Logger = slog.Default()
 	}
 
-	if err := wrapper.Node.Run(ctx, state); err != nil {
-		if wrapper.MaxRetryTimes <= 0 {
-			wrapper.MaxRetryTimes = 1
+	if wrapper.MaxRetryTimes <= 0 {
+		wrapper.MaxRetryTimes = 1
+	}
+
+	nodeName := "unknown"
+
+	if nameable, ok := wrapper.Node.(ogcore.Nameable); ok {
+		nodeName = nameable.Name()
+	}
+
+	startTime := time.Now()
+
+	var delay time.Duration
+	if wrapper.RetryDelay != nil {
+		delay = *wrapper.RetryDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		if wrapper.AttemptStateKey != "" {
+			state.Set(wrapper.AttemptStateKey, attempt)
 		}
 
-		nodeName := "unknown"
+		err := wrapper.Node.Run(context.WithValue(ctx, retryAttemptKey{}, attempt), state)
+		if err == nil {
+			return nil
+		}
+
+		if attempt > wrapper.MaxRetryTimes || ctx.Err() != nil {
+			return err
+		}
+
+		if retry, condErr := wrapper.shouldRetry(err, attempt, state); condErr != nil {
+			return fmt.Errorf("%w, check retry condition failed: %v", err, condErr)
+		} else if !retry {
+			return err
+		}
 
-		if nameable, ok := wrapper.Node.(ogcore.Nameable); ok {
-			nodeName = nameable.Name()
+		wait := delay
+		if wrapper.Jitter > 0 && wait > 0 {
+			wait -= time.Duration(rand.Float64() * min(wrapper.Jitter, 1) * float64(wait))
 		}
 
-		for i := wrapper.MaxRetryTimes; i > 0; i-- {
-			if wrapper.RetryDelay != nil {
-				time.Sleep(*wrapper.RetryDelay)
+		if wrapper.MaxElapsedTime > 0 && time.Since(startTime)+wait > wrapper.MaxElapsedTime {
+			return err
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("%w, retry canceled: %w", err, ctx.Err())
 			}
+		}
+
+		wrapper.Warn("retry failed node", "NodeName", nodeName, "Error", err, "Attempt", attempt+1)
+
+		ograph.EmitLifecycle(ctx, wrapper.EventBus, ograph.EventNodeRetry, state, &ograph.LifecycleEvent{
+			Node:     nodeName,
+			Err:      err,
+			Attempt:  attempt + 1,
+			Duration: time.Since(startTime),
+		})
 
-			wrapper.Warn("retry failed node", "NodeName", nodeName, "Error", err)
+		if wrapper.BackoffFactor > 1 {
+			delay = time.Duration(float64(delay) * wrapper.BackoffFactor)
 
-			if err := wrapper.Node.Run(ctx, state); err != nil {
-				if i == 1 {
-					return err
-				}
-			} else {
-				return nil
+			if wrapper.MaxRetryDelay > 0 && delay > wrapper.MaxRetryDelay {
+				delay = wrapper.MaxRetryDelay
 			}
 		}
 	}
+}
+
+func (wrapper *RetryWrapper) shouldRetry(err error, attempt int, state ogcore.State) (bool, error) {
+	if wrapper.RetryIf == nil && len(wrapper.RetryOn) == 0 && wrapper.RetryIfExpr == "" {
+		return true, nil
+	}
+
+	if wrapper.RetryIf != nil && wrapper.RetryIf(err) {
+		return true, nil
+	}
+
+	for _, target := range wrapper.RetryOn {
+		if errors.Is(err, target) {
+			return true, nil
+		}
+	}
+
+	if wrapper.RetryIfExpr != "" {
+		wrapper.compileOnce.Do(wrapper.compile)
+
+		if wrapper.compileErr != nil {
+			return false, wrapper.compileErr
+		}
+
+		return wrapper.retryIf.RunBool(state, map[string]any{"err": err.Error(), "attempt": attempt})
+	}
+
+	return false, nil
+}
 
-	return nil
+func (wrapper *RetryWrapper) compile() {
+	wrapper.retryIf, wrapper.compileErr = internal.CompileStateExpr(wrapper.RetryIfExpr)
 }
 
 func NewRetryWrapper(times int) ogcore.Node {
@@ -0,0 +1,203 @@
+package ogimpl
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/symphony09/ograph"
+	"github.com/symphony09/ograph/internal"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var SingleFlightWrapperFactory = func() ogcore.Node {
+	return &SingleFlightWrapper{}
+}
+
+// SingleFlightWrapper lets only one execution of wrapped node proceed for concurrent runs with the same key,
+// other runs wait for it, and receive the state keys it writes and its error.
+//
+// The key is result of KeyExpr evaluated with state, or returned by KeyFn, all runs share one key if neither is set.
+// Without GroupKey, executions are shared by runs of the same pipeline only. Wrappers with the same GroupKey
+// share executions across pipelines.
+//
+// The execution runs in the run which starts it, with its ctx, reading its state and writing into an overlay,
+// the written values are set into state of the starting run and each waiting run when it ends. The values are
+// shared by all of them, not copied, so they should not be mutated. If the starting run is canceled before
+// the execution succeeds, waiting runs start a new execution.
+type SingleFlightWrapper struct {
+	ograph.BaseEventWrapper
+
+	GroupKey string
+
+	KeyExpr string
+	KeyFn   func(ctx context.Context, state ogcore.State) (string, error)
+
+	compileOnce sync.Once
+	keyFn       func(ctx context.Context, state ogcore.State) (string, error)
+	compileErr  error
+}
+
+func (wrapper *SingleFlightWrapper) Run(ctx context.Context, state ogcore.State) error {
+	key, err := wrapper.key(ctx, state)
+	if err != nil {
+		return fmt.Errorf("can't get single flight key of %s, err: %w", wrapper.Name(), err)
+	}
+
+	for {
+		f, isLeader := flights.join(wrapper.flightKey(key))
+
+		if isLeader {
+			f.run(ctx, wrapper.Node, state)
+			f.apply(state)
+
+			return f.err
+		}
+
+		select {
+		case <-f.done:
+			// the starting run is canceled, start over
+			if f.abandoned {
+				continue
+			}
+
+			f.apply(state)
+
+			return f.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// flightKey returns key of flight in group, flights without GroupKey are scoped to event bus of pipeline.
+func (wrapper *SingleFlightWrapper) flightKey(key string) flightKey {
+	if wrapper.GroupKey != "" {
+		return flightKey{group: wrapper.GroupKey, key: key}
+	}
+
+	if wrapper.EventBus == nil {
+		return flightKey{scope: wrapper, group: wrapper.Name(), key: key}
+	}
+
+	return flightKey{scope: wrapper.EventBus, group: wrapper.Name(), key: key}
+}
+
+func (wrapper *SingleFlightWrapper) key(ctx context.Context, state ogcore.State) (string, error) {
+	if wrapper.KeyFn != nil {
+		return wrapper.KeyFn(ctx, state)
+	}
+
+	if wrapper.KeyExpr == "" {
+		return "", nil
+	}
+
+	wrapper.compileOnce.Do(wrapper.compile)
+
+	if wrapper.compileErr != nil {
+		return "", wrapper.compileErr
+	}
+
+	return wrapper.keyFn(ctx, state)
+}
+
+func (wrapper *SingleFlightWrapper) compile() {
+	stateExpr, err := internal.CompileStateExpr(wrapper.KeyExpr)
+	if err != nil {
+		wrapper.compileErr = err
+		return
+	}
+
+	wrapper.keyFn = func(ctx context.Context, state ogcore.State) (string, error) {
+		output, err := stateExpr.Run(state, nil)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprint(output), nil
+	}
+}
+
+type flightKey struct {
+	scope any
+	group string
+	key   string
+}
+
+type flight struct {
+	key flightKey
+
+	done   chan struct{}
+	writes map[any]any
+	err    error
+
+	// abandoned is set if the starting run is canceled before the flight succeeds.
+	abandoned bool
+}
+
+// run runs node in the starting run, waiting runs are released when it returns.
+func (f *flight) run(ctx context.Context, node ogcore.Node, state ogcore.State) {
+	overlay := NewOverlayState(state)
+
+	defer func() {
+		if p := recover(); p != nil {
+			f.err = fmt.Errorf("single flight panic, info: %v", p)
+		}
+
+		overlay.RLock()
+		f.writes = maps.Clone(overlay.Upper)
+		overlay.RUnlock()
+
+		f.abandoned = f.err != nil && ctx.Err() != nil
+
+		flights.remove(f)
+		close(f.done)
+	}()
+
+	f.err = node.Run(ctx, overlay)
+}
+
+// apply sets values written by the flight into state.
+func (f *flight) apply(state ogcore.State) {
+	if batchSetter, ok := state.(ogcore.BatchSetter); ok {
+		batchSetter.SetBatch(f.writes)
+	} else {
+		for k, v := range f.writes {
+			state.Set(k, v)
+		}
+	}
+}
+
+type flightGroup struct {
+	flights map[flightKey]*flight
+
+	sync.Mutex
+}
+
+var flights = &flightGroup{flights: make(map[flightKey]*flight)}
+
+// join returns the flight of key, it's a new one to be run by the caller if there is no flight.
+func (group *flightGroup) join(key flightKey) (*flight, bool) {
+	group.Lock()
+	defer group.Unlock()
+
+	if f := group.flights[key]; f != nil {
+		return f, false
+	}
+
+	f := &flight{key: key, done: make(chan struct{})}
+
+	group.flights[key] = f
+
+	return f, true
+}
+
+func (group *flightGroup) remove(f *flight) {
+	group.Lock()
+	defer group.Unlock()
+
+	if group.flights[f.key] == f {
+		delete(group.flights, f.key)
+	}
+}
@@ -17,7 +17,7 @@ var TimeoutWrapperFactory = func() ogcore.Node {
 var ErrTimeout = errors.New("the running time exceeds the limit")
 
 type TimeoutWrapper struct {
-	ograph.BaseWrapper
+	ograph.BaseEventWrapper
 
 	Timeout time.Duration
 }
@@ -48,6 +48,12 @@ func (wrapper *TimeoutWrapper) Run(ctx context.Context, state ogcore.State) erro
 		timer.Stop()
 		return err
 	case <-timer.C:
+		ograph.EmitLifecycle(ctx, wrapper.EventBus, ograph.EventNodeTimeout, state, &ograph.LifecycleEvent{
+			Node:     wrapper.Name(),
+			Duration: wrapper.Timeout,
+			Err:      ErrTimeout,
+		})
+
 		return fmt.Errorf("node failed after %s, error: %w", wrapper.Timeout, ErrTimeout)
 	}
 }
@@ -9,9 +9,7 @@ var Rely = func(dependencies ...*Element) Op {
 				pipeline.Register(dep)
 			}
 
-			if pipeline.elements[dep.Name] == dep {
-				pipeline.graph.AddEdge(dep.Name, element.Name)
-			}
+			pipeline.connect(dep, element)
 		}
 	}
 }
@@ -23,9 +21,7 @@ var Then = func(nextElements ...*Element) Op {
 				pipeline.Register(next)
 			}
 
-			if pipeline.elements[next.Name] == next {
-				pipeline.graph.AddEdge(element.Name, next.Name)
-			}
+			pipeline.connect(element, next)
 		}
 	}
 }
@@ -48,11 +44,19 @@ var Branch = func(elements ...*Element) Op {
 				pipeline.Register(next)
 			}
 
-			if pipeline.elements[next.Name] == next {
-				pipeline.graph.AddEdge(prev.Name, next.Name)
-			}
+			pipeline.connect(prev, next)
 
 			prev = next
 		}
 	}
 }
+
+// connect adds edge between registered elements,
+// edge to element which is not the registered one with same name is kept as dangling for check.
+func (pipeline *Pipeline) connect(from, to *Element) {
+	if pipeline.elements[from.Name] == from && pipeline.elements[to.Name] == to {
+		pipeline.graph.AddEdge(from.Name, to.Name)
+	} else {
+		pipeline.dangling = append(pipeline.dangling, [2]string{from.Name, to.Name})
+	}
+}
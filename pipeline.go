@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"iter"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/symphony09/eventd"
@@ -19,6 +22,11 @@ import (
 
 var ErrFactoryNotFound error = errors.New("factory not found")
 var ErrSingletonNotSet error = errors.New("single node not set")
+var ErrDanglingEdge error = internal.ErrDanglingEdge
+var ErrCommitFailed error = internal.ErrCommitFailed
+var ErrRollbackFailed error = internal.ErrRollbackFailed
+
+type CycleError = internal.CycleError
 
 type Pipeline struct {
 	BaseNode
@@ -29,12 +37,33 @@ type Pipeline struct {
 	elements map[string]*Element
 	pool     internal.WorkerPool
 	eventBus *eventd.EventBus[ogcore.State]
+	forwards sync.Map
+	dangling [][2]string
+
+	// lifecycleSubs is number of subscriptions matching lifecycle events.
+	lifecycleSubs atomic.Int32
+
+	// instances are instantiations of templates, dumped as template instances if they are unchanged.
+	instances []templateRef
 
 	Interrupts       iter.Seq[string]
 	ParallelismLimit int
 	DisablePool      bool
 	EnableMonitor    bool
 	SlowThreshold    time.Duration
+
+	// Deterministic runs ready nodes one at a time in order of priority then name,
+	// or in a random order generated from Seed if it is not 0, so that a run can be replayed.
+	// Nested pipelines and clusters follow the mode of the outer pipeline.
+	Deterministic bool
+	Seed          int64
+
+	// OnProgress is called when a node is completed in runs started by Start, it should return quickly.
+	OnProgress func(progress RunProgress)
+
+	// Journal records runs so that they can be resumed after crash, see Resume.
+	// Nested pipelines share run ID of outer run, so it should be set on the outermost pipeline only.
+	Journal *Journal
 }
 
 func (pipeline *Pipeline) Register(e *Element, ops ...Op) *Pipeline {
@@ -59,6 +88,23 @@ func (pipeline *Pipeline) ForEachElem(op func(e *Element)) *Pipeline {
 }
 
 func (pipeline *Pipeline) Check() error {
+	cycleErr := new(CycleError)
+
+	if err := pipeline.check("", []*Pipeline{pipeline}, []string{""}, cycleErr); err != nil {
+		return err
+	}
+
+	if len(cycleErr.Cycles) != 0 {
+		return cycleErr
+	}
+
+	return nil
+}
+
+// check validates elements and edges of pipeline, cycles are collected into cycleErr with names qualified by prefix.
+// stack holds the nesting pipelines and the qualified names of elements which lead into them,
+// so that a pipeline containing itself can be reported as a cycle instead of recursing forever.
+func (pipeline *Pipeline) check(prefix string, stack []*Pipeline, entries []string, cycleErr *CycleError) error {
 	factories := pipeline.Builder.Factories
 	if factories == nil {
 		factories = global.Factories.Clone()
@@ -84,7 +130,15 @@ func (pipeline *Pipeline) Check() error {
 			if elem.Singleton == nil {
 				return fmt.Errorf("%w, name: %s", ErrSingletonNotSet, elem.Name)
 			} else if subPipeline, ok := elem.Singleton.(*Pipeline); ok {
-				if err := subPipeline.Check(); err != nil {
+				entry := prefix + elem.Name
+
+				if i := slices.Index(stack, subPipeline); i >= 0 {
+					cycle := append(slices.Clone(entries[i+1:]), entry)
+					cycleErr.Cycles = append(cycleErr.Cycles, append(cycle, cycle[0]))
+					return nil
+				}
+
+				if err := subPipeline.check(entry+"/", append(stack, subPipeline), append(entries, entry), cycleErr); err != nil {
 					return err
 				}
 			}
@@ -93,63 +147,62 @@ func (pipeline *Pipeline) Check() error {
 		return nil
 	}
 
-	for _, vertex := range pipeline.graph.Vertices {
-		if err := elemCheck(vertex.Elem); err != nil {
+	for _, name := range slices.Sorted(maps.Keys(pipeline.graph.Vertices)) {
+		if err := elemCheck(pipeline.graph.Vertices[name].Elem); err != nil {
 			return err
 		}
 	}
 
-	return pipeline.graph.Check()
-}
+	dangling := pipeline.graph.DanglingEdges()
+	dangling = append(dangling, pipeline.dangling...)
 
-func (pipeline *Pipeline) Run(ctx context.Context, state ogcore.State) error {
-	newCtx, newState, worker, params, afterRun, err := pipeline.prepare(ctx, state)
-	if err != nil {
-		return err
+	for edge := range pipeline.graph.Edges {
+		if pipeline.elements[edge.From.Name] != edge.From.Elem || pipeline.elements[edge.To.Name] != edge.To.Elem {
+			dangling = append(dangling, [2]string{edge.From.Name, edge.To.Name})
+		}
 	}
 
-	defer afterRun()
+	if len(dangling) != 0 {
+		for i := range dangling {
+			dangling[i] = [2]string{prefix + dangling[i][0], prefix + dangling[i][1]}
+		}
 
-	return worker.Work(newCtx, newState, params)
-}
+		return fmt.Errorf("%w, edges: %s", ErrDanglingEdge, internal.FormatEdges(dangling))
+	}
 
-func (pipeline *Pipeline) AsyncRun(ctx context.Context, state ogcore.State) (pause, continueRun func(), wait func() error) {
-	pause, continueRun = func() {}, func() {}
+	for _, cycle := range pipeline.graph.FindCycles() {
+		for i := range cycle {
+			cycle[i] = prefix + cycle[i]
+		}
+
+		cycleErr.Cycles = append(cycleErr.Cycles, cycle)
+	}
+
+	return nil
+}
 
+func (pipeline *Pipeline) Run(ctx context.Context, state ogcore.State) error {
 	newCtx, newState, worker, params, afterRun, err := pipeline.prepare(ctx, state)
 	if err != nil {
-		wait = func() error {
-			return err
-		}
-		return
+		return err
 	}
 
-	errCh := make(chan error, 1)
-	go func() {
-		defer afterRun()
-		errCh <- worker.Work(newCtx, newState, params)
+	defer func() {
+		// worker of nested pipeline is kept until its transactions adopted by outer run end
+		if params.Adopted {
+			internal.TransactionScopeFrom(newCtx).AfterEnd(afterRun)
+		} else {
+			afterRun()
+		}
 	}()
 
-	params.ContinueCond = sync.NewCond(&sync.Mutex{})
-
-	pause = func() {
-		params.ContinueCond.L.Lock()
-		params.Pause = true
-		params.ContinueCond.L.Unlock()
-	}
-
-	continueRun = func() {
-		params.ContinueCond.L.Lock()
-		params.Pause = false
-		params.ContinueCond.L.Unlock()
-		params.ContinueCond.Broadcast()
-	}
+	return worker.Work(newCtx, newState, params)
+}
 
-	wait = func() error {
-		return <-errCh
-	}
+func (pipeline *Pipeline) AsyncRun(ctx context.Context, state ogcore.State) (pause, continueRun func(), wait func() error) {
+	handle := pipeline.Start(ctx, state)
 
-	return
+	return handle.Pause, handle.Continue, handle.Wait
 }
 
 func (pipeline *Pipeline) prepare(ctx context.Context, state ogcore.State) (context.Context, ogcore.State,
@@ -193,6 +246,47 @@ func (pipeline *Pipeline) prepare(ctx context.Context, state ogcore.State) (cont
 	}
 	params.Interrupts = pipeline.Interrupts
 
+	if pipeline.Deterministic {
+		params.Deterministic, params.Seed = true, pipeline.Seed
+		ctx = ogcore.WithDeterminism(ctx, ogcore.Determinism{Seed: pipeline.Seed})
+	} else if d, ok := ogcore.DeterminismFrom(ctx); ok {
+		params.Deterministic, params.Seed = true, d.Seed
+	}
+
+	runID := ogcore.RunIDFrom(ctx)
+	if runID == "" {
+		runID = newRunID()
+		ctx = ogcore.WithRunID(ctx, runID)
+	}
+
+	bus, pipelineName := pipeline.eventBus, pipeline.Name()
+
+	// events of nested pipelines are forwarded to outer bus, so they are emitted if outer run emits.
+	if pipeline.lifecycleSubs.Load() > 0 || lifecycleListened(ctx) {
+		ctx = context.WithValue(ctx, lifecycleListenedKey{}, true)
+
+		params.Listener = func(event string, name string, elapsed time.Duration, err error) {
+			if name == "" {
+				name = pipelineName
+			}
+
+			EmitPayload(bus, event, state, &LifecycleEvent{Node: name, RunID: runID, Duration: elapsed, Err: err})
+		}
+	}
+
+	if pipeline.Journal != nil {
+		journalState, err := pipeline.Journal.track(runID, pipelineName, state, params, pipeline.Logger)
+		if err != nil {
+			if !pipeline.DisablePool {
+				pool.Put(worker)
+			}
+
+			return ctx, state, nil, nil, nil, err
+		}
+
+		state = journalState
+	}
+
 	afterRun := func() {
 		if !pipeline.DisablePool {
 			pool.Put(worker)
@@ -231,7 +325,77 @@ func (pipeline *Pipeline) ResetPool() {
 	pipeline.pool.Reset()
 }
 
+// Clone returns a deep copy of pipeline definition, with a fresh worker pool and event bus.
+// Nested pipelines are cloned too, see Element.Clone for how Singleton nodes are copied.
+func (pipeline *Pipeline) Clone() *Pipeline {
+	return pipeline.clone(make(map[*Pipeline]*Pipeline))
+}
+
+func (pipeline *Pipeline) clone(pipelines map[*Pipeline]*Pipeline) *Pipeline {
+	// pipeline nested in itself, reuse the clone to keep the same structure
+	if clone := pipelines[pipeline]; clone != nil {
+		return clone
+	}
+
+	clone := NewPipeline()
+	pipelines[pipeline] = clone
+
+	clone.BaseNode = pipeline.BaseNode
+	clone.Logger = pipeline.Logger
+	clone.Interrupts = pipeline.Interrupts
+	clone.ParallelismLimit = pipeline.ParallelismLimit
+	clone.DisablePool = pipeline.DisablePool
+	clone.EnableMonitor = pipeline.EnableMonitor
+	clone.SlowThreshold = pipeline.SlowThreshold
+	clone.Deterministic = pipeline.Deterministic
+	clone.Seed = pipeline.Seed
+	clone.OnProgress = pipeline.OnProgress
+	clone.Journal = pipeline.Journal
+
+	if pipeline.Factories != nil {
+		clone.Factories = pipeline.Factories.Clone()
+	}
+
+	if size := pipeline.pool.CacheSize(); size > 0 {
+		clone.pool = *internal.NewWorkerPool(size)
+	}
+
+	clone.dangling = slices.Clone(pipeline.dangling)
+	clone.instances = slices.Clone(pipeline.instances)
+
+	elements := make(map[*Element]*Element, len(pipeline.elements))
+
+	cloneElem := func(e *Element) *Element {
+		if elements[e] == nil {
+			elements[e] = e.clone(pipelines)
+		}
+
+		return elements[e]
+	}
+
+	for name, e := range pipeline.elements {
+		clone.elements[name] = cloneElem(e)
+	}
+
+	for name, v := range pipeline.graph.Vertices {
+		clone.graph.AddVertex(name, cloneElem(v.Elem))
+	}
+
+	for name, v := range pipeline.graph.Vertices {
+		for _, next := range v.Next {
+			clone.graph.AddEdge(name, next.Name)
+		}
+	}
+
+	return clone
+}
+
 func (pipeline *Pipeline) DumpGraph() ([]byte, error) {
+	return json.Marshal(pipeline.marshaler())
+}
+
+// marshaler returns graph marshaler of pipeline, with graphs of nested pipelines and templates.
+func (pipeline *Pipeline) marshaler() *internal.GraphMarshaler[*Element] {
 	marshaler := internal.NewGraphMarshaler(pipeline.graph)
 
 	for _, v := range pipeline.graph.Vertices {
@@ -241,12 +405,14 @@ func (pipeline *Pipeline) DumpGraph() ([]byte, error) {
 					marshaler.SubGraphs = make(map[string]*internal.GraphMarshaler[*Element])
 				}
 
-				marshaler.SubGraphs[v.Name] = internal.NewGraphMarshaler(subPipeline.graph)
+				marshaler.SubGraphs[v.Name] = subPipeline.marshaler()
 			}
 		}
 	}
 
-	return json.Marshal(marshaler)
+	pipeline.dumpTemplates(marshaler)
+
+	return marshaler
 }
 
 func (pipeline *Pipeline) LoadGraph(data []byte) error {
@@ -256,24 +422,114 @@ func (pipeline *Pipeline) LoadGraph(data []byte) error {
 		return err
 	}
 
+	if err := pipeline.loadMarshaler(marshaler); err != nil {
+		return err
+	}
+
+	pipeline.ResetPool()
+
+	return nil
+}
+
+func (pipeline *Pipeline) loadMarshaler(marshaler *internal.GraphMarshaler[*Element]) error {
 	pipeline.graph = marshaler.GenerateGraph()
+	pipeline.elements = make(map[string]*Element, len(pipeline.graph.Vertices))
+	pipeline.dangling = nil
+	pipeline.instances = nil
+
+	for name, v := range pipeline.graph.Vertices {
+		pipeline.elements[name] = v.Elem
+	}
 
+	if len(marshaler.Instances) != 0 {
+		templates := make(map[string]*Template, len(marshaler.Templates))
+
+		for name, tplMarshaler := range marshaler.Templates {
+			tpl := NewTemplate()
+			tpl.Name = name
+			tpl.fragment.Factories = pipeline.Factories
+
+			if err := tpl.fragment.loadMarshaler(tplMarshaler); err != nil {
+				return err
+			}
+
+			templates[name] = tpl
+		}
+
+		for _, ref := range marshaler.Instances {
+			if tpl := templates[ref.Template]; tpl == nil {
+				return fmt.Errorf("%w, name: %s", ErrTemplateNotFound, ref.Template)
+			} else if _, err := pipeline.Instantiate(tpl, ref.Prefix, ref.Params); err != nil {
+				return err
+			}
+		}
+
+		// edges to instantiated elements can be added now
+		for _, e := range marshaler.Edges {
+			pipeline.graph.AddEdge(e[0], e[1])
+		}
+	}
+
+	// sub graphs are loaded after instantiation, instantiated elements may be nested pipelines too
 	for name, subMarshaler := range marshaler.SubGraphs {
 		if v, ok := pipeline.graph.Vertices[name]; ok {
 			subPipeline := NewPipeline()
 			subPipeline.Factories = pipeline.Factories
-			subPipeline.graph = subMarshaler.GenerateGraph()
+
+			if err := subPipeline.loadMarshaler(subMarshaler); err != nil {
+				return err
+			}
+
 			v.Elem.Singleton = subPipeline
 		}
 	}
 
-	pipeline.ResetPool()
+	// edges to unknown vertices are dropped by graph, keep them for check
+	for _, e := range marshaler.Edges {
+		if pipeline.graph.Vertices[e[0]] == nil || pipeline.graph.Vertices[e[1]] == nil {
+			pipeline.dangling = append(pipeline.dangling, e)
+		}
+	}
 
 	return nil
 }
 
+// Subscribe subscribes events of pipeline. Subscriptions matching lifecycle events take effect from the next run.
 func (pipeline *Pipeline) Subscribe(callback eventd.CallBack[ogcore.State], ops ...eventd.Op) (cancel func(), err error) {
-	return pipeline.eventBus.Subscribe(callback, ops...)
+	unsubscribe, err := pipeline.eventBus.Subscribe(callback, ops...)
+	if err != nil || !matchLifecycle(ops) {
+		return unsubscribe, err
+	}
+
+	pipeline.lifecycleSubs.Add(1)
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			pipeline.lifecycleSubs.Add(-1)
+		})
+
+		unsubscribe()
+	}, nil
+}
+
+// forwardTo forwards events of pipeline to bus of outer pipeline, events are passed as they are to keep payloads.
+// Workers are built many times, the subscription is only made once for each bus.
+func (pipeline *Pipeline) forwardTo(bus *eventd.EventBus[ogcore.State]) {
+	if bus == nil || bus == pipeline.eventBus {
+		return
+	}
+
+	if _, loaded := pipeline.forwards.LoadOrStore(bus, true); loaded {
+		return
+	}
+
+	// forwarding is not counted as lifecycle subscription, runs nested in listened runs emit lifecycle events.
+	pipeline.eventBus.Subscribe(func(event string, obj ogcore.State) bool {
+		bus.Emit(event, obj)
+		return true
+	}, eventd.On(".*"))
 }
 
 func NewPipeline() *Pipeline {
@@ -2,15 +2,23 @@ package ograph
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"os"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/symphony09/eventd"
+	"github.com/symphony09/ograph/global"
 	"github.com/symphony09/ograph/internal"
 	"github.com/symphony09/ograph/ogcore"
 )
@@ -93,17 +101,105 @@ func TestPipeline_Check(t *testing.T) {
 
 	t2.AsVirtual()
 	p.Register(t1, Then(t1))
-	wantErr = fmt.Errorf("found cycle between vertices: %v", []string{"t1"})
+	wantCycles := [][]string{{"t1", "t1"}}
 
-	if err := p.Check(); err.Error() != wantErr.Error() {
-		t.Errorf("p.Check() = %v, want %v", err, wantErr)
+	var cycleErr *CycleError
+
+	if err := p.Check(); !errors.As(err, &cycleErr) || !reflect.DeepEqual(cycleErr.Cycles, wantCycles) {
+		t.Errorf("p.Check() = %v, want cycles %v", err, wantCycles)
 	}
 
 	p2 := NewPipeline()
 	p2.Register(NewElement("sub_p").UseNode(p))
+	wantCycles = [][]string{{"sub_p/t1", "sub_p/t1"}}
+
+	if err := p2.Check(); !errors.As(err, &cycleErr) || !reflect.DeepEqual(cycleErr.Cycles, wantCycles) {
+		t.Errorf("p2.Check() = %v, want cycles %v", err, wantCycles)
+	}
+}
+
+func TestPipeline_Check_Cycle(t *testing.T) {
+	p := NewPipeline()
+
+	a := NewElement("a").AsVirtual()
+	b := NewElement("b").AsVirtual()
+	c := NewElement("c").AsVirtual()
+	d := NewElement("d").AsVirtual()
+	e := NewElement("e").AsVirtual()
+
+	// d and e are only downstream of cycles, they should not be reported.
+	p.Register(a, Then(b)).Register(b, Then(c, d)).Register(c, Then(a, b)).Register(d, Then(e))
+
+	wantErr := "found cycle: a -> b -> c -> a; b -> c -> b"
+
+	if err := p.Check(); err == nil || err.Error() != wantErr {
+		t.Errorf("p.Check() = %v, want %s", err, wantErr)
+	}
+
+	p2 := NewPipeline()
+	p3 := NewPipeline()
+
+	p2.Register(NewElement("x").AsVirtual(), Then(NewElement("sub_3").UseNode(p3)))
+	p3.Register(NewElement("sub_2").UseNode(p2))
+
+	wantErr = "found cycle: sub_3 -> sub_3/sub_2 -> sub_3"
+
+	if err := p2.Check(); err == nil || err.Error() != wantErr {
+		t.Errorf("p2.Check() = %v, want %s", err, wantErr)
+	}
+
+	// complete graph has exponentially many cycles, only MaxCycles of them are reported.
+	p4 := NewPipeline()
+
+	dense := make([]*Element, 16)
+	for i := range dense {
+		dense[i] = NewElement(fmt.Sprintf("n%02d", i)).AsVirtual()
+	}
+
+	for _, from := range dense {
+		for _, to := range dense {
+			if from != to {
+				p4.Register(from, Then(to))
+			}
+		}
+	}
+
+	var cycleErr *CycleError
+
+	done := make(chan error, 1)
+	go func() { done <- p4.Check() }()
+
+	select {
+	case err := <-done:
+		if !errors.As(err, &cycleErr) || len(cycleErr.Cycles) != internal.MaxCycles {
+			t.Errorf("p4.Check() = %v, want %d cycles", err, internal.MaxCycles)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("p4.Check() does not finish on dense graph")
+	}
+}
+
+func TestPipeline_Check_Dangling(t *testing.T) {
+	p := NewPipeline()
+
+	t1 := NewElement("t1").AsVirtual()
+	p.Register(t1)
+
+	// t1 is registered by another element with same name, so the edge is dangling.
+	p.Register(NewElement("t2").AsVirtual(), Rely(NewElement("t1").AsVirtual()))
+
+	if err := p.Check(); !errors.Is(err, ErrDanglingEdge) || !strings.Contains(err.Error(), "t1 -> t2") {
+		t.Errorf("p.Check() = %v, want %v", err, ErrDanglingEdge)
+	}
+
+	p2 := NewPipeline()
+
+	if err := p2.LoadGraph([]byte(`{"Vertices":{"a":{"Name":"a","Virtual":true}},"Edges":[["a","b"]]}`)); err != nil {
+		t.Errorf("p2.LoadGraph() got err = %v, want nil", err)
+	}
 
-	if err := p2.Check(); err.Error() != wantErr.Error() {
-		t.Errorf("p2.Check() = %v, want %v", err, wantErr)
+	if err := p2.Check(); !errors.Is(err, ErrDanglingEdge) || !strings.Contains(err.Error(), "a -> b") {
+		t.Errorf("p2.Check() = %v, want %v", err, ErrDanglingEdge)
 	}
 }
 
@@ -493,3 +589,1314 @@ func TestPipeline_DumpDOT(t *testing.T) {
 		}
 	}
 }
+
+func TestPipeline_Edit(t *testing.T) {
+	var trace []string
+
+	newElem := func(name string) *Element {
+		return NewElement(name).UseFn(func() error {
+			trace = append(trace, name)
+			return nil
+		})
+	}
+
+	p := NewPipeline()
+	p.ParallelismLimit = 1
+
+	a, b, c := newElem("a"), newElem("b"), newElem("c")
+	p.Register(a, Branch(b, c))
+
+	if err := p.Run(context.Background(), nil); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	if err := p.InsertBetween("a", "b", newElem("x")); err != nil {
+		t.Errorf("p.InsertBetween() got err = %v, want nil", err)
+	}
+
+	if err := p.Replace("c", newElem("y")); err != nil {
+		t.Errorf("p.Replace() got err = %v, want nil", err)
+	}
+
+	trace = nil
+
+	if err := p.Run(context.Background(), nil); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	if want := []string{"a", "x", "b", "y"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("got trace = %v, want %v", trace, want)
+	}
+
+	if err := p.RemoveEdge("x", "b"); err != nil {
+		t.Errorf("p.RemoveEdge() got err = %v, want nil", err)
+	}
+
+	if err := p.RemoveEdge("x", "b"); !errors.Is(err, ErrEdgeNotFound) {
+		t.Errorf("p.RemoveEdge() got err = %v, want %v", err, ErrEdgeNotFound)
+	}
+
+	if err := p.Unregister("x"); err != nil {
+		t.Errorf("p.Unregister() got err = %v, want nil", err)
+	}
+
+	if err := p.Unregister("x"); !errors.Is(err, ErrElementNotFound) {
+		t.Errorf("p.Unregister() got err = %v, want %v", err, ErrElementNotFound)
+	}
+
+	if err := p.Replace("a", NewElement("b")); !errors.Is(err, ErrElementExists) {
+		t.Errorf("p.Replace() got err = %v, want %v", err, ErrElementExists)
+	}
+
+	if err := p.Check(); err != nil {
+		t.Errorf("p.Check() got err = %v, want nil", err)
+	}
+
+	if len(p.elements) != 3 || len(p.graph.Vertices) != 3 || len(p.graph.Edges) != 1 {
+		t.Errorf("got %d elements, %d vertices, %d edges, want 3, 3, 1",
+			len(p.elements), len(p.graph.Vertices), len(p.graph.Edges))
+	}
+
+	if v := p.graph.Vertices["b"]; len(v.Dependencies) != 0 || len(v.Next) != 1 || v.Next[0].Name != "y" {
+		t.Errorf("got vertex b dependencies = %d, next = %v, want 0, [y]", len(v.Dependencies), v.Next)
+	}
+
+	// dangling edges follow renamed element
+	p2 := NewPipeline()
+	p2.Register(NewElement("t1").AsVirtual()).
+		Register(NewElement("t2").AsVirtual(), Rely(NewElement("t1").AsVirtual()))
+
+	if err := p2.Replace("t2", NewElement("t3").AsVirtual()); err != nil {
+		t.Errorf("p2.Replace() got err = %v, want nil", err)
+	}
+
+	if err := p2.Check(); !errors.Is(err, ErrDanglingEdge) || !strings.Contains(err.Error(), "t1 -> t3") {
+		t.Errorf("p2.Check() = %v, want dangling edge t1 -> t3", err)
+	}
+}
+
+func TestPipeline_Clone(t *testing.T) {
+	p := NewPipeline()
+	p.RegisterFactory("t", func() ogcore.Node { return &TNode{} })
+
+	shared := &BaseNode{}
+	tx := &TxNode{ParameterX: "x"}
+
+	sub := NewPipeline()
+	sub.RegisterFactory("t", func() ogcore.Node { return &TNode{} })
+	sub.Register(NewElement("sub_t").UseFactory("t").Params("ParameterX", "1"))
+
+	t1 := NewElement("t1").UseFactory("t").Params("ParameterX", "1").Wrap("w").
+		WrapByAlias("w", "w2").Params("Nested", map[string]any{"list": []any{"a", map[string]any{"k": "v"}}})
+	t2 := NewElement("t2").UseNode(shared)
+	t3 := NewElement("t3").UseNode(tx)
+	t4 := NewElement("t4").UseNode(sub)
+
+	p.Register(t1, Then(t2, t3)).Register(t4, Rely(t2, t3))
+
+	clone := p.Clone()
+
+	clone.elements["t1"].Params("ParameterX", "2").Wrap("w3")
+	clone.elements["t1"].WrapperAlias["w2"] = "w4"
+
+	nested := clone.elements["t1"].ParamsMap["Nested"].(map[string]any)
+	nested["list"].([]any)[1].(map[string]any)["k"] = "changed"
+	nested["new"] = true
+	clone.RegisterFactory("t2", func() ogcore.Node { return &TNode{} })
+
+	if err := clone.Unregister("t3"); err != nil {
+		t.Errorf("clone.Unregister() got err = %v, want nil", err)
+	}
+
+	if t1.ParamsMap["ParameterX"] != "1" || len(t1.Wrappers) != 2 || t1.WrapperAlias["w2"] != "w" {
+		t.Errorf("got t1 changed by clone, params = %v, wrappers = %v, alias = %v",
+			t1.ParamsMap, t1.Wrappers, t1.WrapperAlias)
+	}
+
+	if nested := t1.ParamsMap["Nested"].(map[string]any); len(nested) != 1 ||
+		nested["list"].([]any)[1].(map[string]any)["k"] != "v" {
+		t.Errorf("got nested params of t1 changed by clone, params = %v", nested)
+	}
+
+	if len(p.elements) != 4 || len(p.graph.Edges) != 4 || p.Factories.Get("t2") != nil {
+		t.Errorf("got p changed by clone, elements = %d, edges = %d", len(p.elements), len(p.graph.Edges))
+	}
+
+	if len(clone.graph.Edges) != 2 || clone.graph.Vertices["t1"].Elem != clone.elements["t1"] {
+		t.Errorf("got clone edges = %d, want 2", len(clone.graph.Edges))
+	}
+
+	if clone.elements["t2"].Singleton != shared {
+		t.Error("got singleton copied, want shared")
+	}
+
+	subClone, ok := clone.elements["t4"].Singleton.(*Pipeline)
+	if !ok || subClone == sub || subClone.elements["sub_t"] == sub.elements["sub_t"] {
+		t.Error("got sub pipeline shared, want cloned")
+	}
+
+	if clone.eventBus == p.eventBus {
+		t.Error("got event bus shared, want new one")
+	}
+
+	clone.elements["t1"].Params("ParameterX", "1").Wrappers = nil
+
+	if err := clone.Run(context.Background(), nil); err != nil {
+		t.Errorf("clone.Run() got err = %v, want nil", err)
+	}
+
+	p.elements["t1"].Wrappers = nil
+
+	if err := p.Run(context.Background(), nil); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	p2 := NewPipeline()
+	p2.Register(NewElement("tx").UseNode(tx))
+
+	if p2.Clone().elements["tx"].Singleton == tx {
+		t.Error("got cloneable singleton shared, want cloned")
+	}
+}
+
+type TEchoNode struct {
+	BaseNode
+	Text string
+	Num  int
+}
+
+func (n *TEchoNode) Run(ctx context.Context, state ogcore.State) error {
+	state.Set(n.Name(), n.Text+strconv.Itoa(n.Num))
+	return nil
+}
+
+func TestPipeline_Instantiate(t *testing.T) {
+	tpl := NewTemplate()
+
+	fetch := NewElement("fetch").UseFactory("echo").Params("Text", "fetch ${source}").Params("Num", "${num}")
+	store := NewElement("store").UseFactory("echo").Params("Text", "${source}")
+
+	tpl.Register(fetch, Then(store))
+
+	p := NewPipeline()
+	p.RegisterFactory("echo", func() ogcore.Node { return &TEchoNode{} })
+
+	a, err := p.Instantiate(tpl, "a_", map[string]any{"source": "db", "num": 1})
+	if err != nil {
+		t.Errorf("p.Instantiate() got err = %v, want nil", err)
+	}
+
+	b, err := p.Instantiate(tpl, "b_", map[string]any{"source": "api", "num": 2})
+	if err != nil {
+		t.Errorf("p.Instantiate() got err = %v, want nil", err)
+	}
+
+	if _, err := p.Instantiate(tpl, "c_", map[string]any{"source": "api"}); !errors.Is(err, ErrTemplateParamNotSet) {
+		t.Errorf("p.Instantiate() got err = %v, want %v", err, ErrTemplateParamNotSet)
+	}
+
+	if _, err := p.Instantiate(tpl, "a_", map[string]any{"source": "db", "num": 1}); !errors.Is(err, ErrElementExists) {
+		t.Errorf("p.Instantiate() got err = %v, want %v", err, ErrElementExists)
+	}
+
+	if len(a.Entries) != 1 || a.Entries[0].Name != "a_fetch" || len(a.Exits) != 1 || a.Exits[0] != a.Elem("store") {
+		t.Errorf("got entries = %v, exits = %v", a.Entries, a.Exits)
+	}
+
+	p.Register(NewElement("start").AsVirtual(), Then(a.Entries...)).
+		Register(NewElement("end").AsVirtual(), Rely(a.Exits...)).
+		Register(b.Elem("store"), Then(a.Entries...))
+
+	if fetch.ParamsMap["Text"] != "fetch ${source}" {
+		t.Errorf("got template params changed to %v", fetch.ParamsMap)
+	}
+
+	state := NewState()
+
+	if err := p.Run(context.Background(), state); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	for key, want := range map[string]string{
+		"a_fetch": "fetch db1", "a_store": "db0", "b_fetch": "fetch api2", "b_store": "api0",
+	} {
+		if got := LoadState[string](state, key); got != want {
+			t.Errorf("got %s = %s, want %s", key, got, want)
+		}
+	}
+
+	// unchanged instances are dumped as template instances, changed ones as normal elements
+	b.Elem("fetch").Params("Text", "fetch api changed")
+
+	data, err := p.DumpGraph()
+	if err != nil {
+		t.Fatalf("p.DumpGraph() got err = %v, want nil", err)
+	}
+
+	var dumped internal.GraphMarshaler[*Element]
+
+	if err := json.Unmarshal(data, &dumped); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dumped.Templates) != 1 || len(dumped.Instances) != 1 || dumped.Instances[0].Prefix != "a_" ||
+		dumped.Vertices["a_fetch"] != nil || dumped.Vertices["b_fetch"] == nil {
+		t.Errorf("got dumped templates = %v, instances = %v", dumped.Templates, dumped.Instances)
+	}
+
+	p3 := NewPipeline()
+	p3.RegisterFactory("echo", func() ogcore.Node { return &TEchoNode{} })
+
+	if err := p3.LoadGraph(data); err != nil {
+		t.Fatalf("p3.LoadGraph() got err = %v, want nil", err)
+	}
+
+	if len(p3.elements) != len(p.elements) || len(p3.graph.Edges) != len(p.graph.Edges) {
+		t.Errorf("got %d elements and %d edges, want %d and %d",
+			len(p3.elements), len(p3.graph.Edges), len(p.elements), len(p.graph.Edges))
+	}
+
+	state = NewState()
+
+	if err := p3.Run(context.Background(), state); err != nil {
+		t.Errorf("p3.Run() got err = %v, want nil", err)
+	}
+
+	if got := LoadState[string](state, "a_fetch") + LoadState[string](state, "b_fetch"); got != "fetch db1fetch api changed2" {
+		t.Errorf("got a_fetch + b_fetch = %s", got)
+	}
+
+	if data2, err := p3.DumpGraph(); err != nil || !strings.Contains(string(data2), `"Instances"`) {
+		t.Errorf("p3.DumpGraph() got %s, err = %v, want template instances", data2, err)
+	}
+
+	p2 := NewPipeline()
+	p2.RegisterFactory("echo", func() ogcore.Node { return &TEchoNode{} })
+
+	err = p2.LoadGraph([]byte(`{
+		"Vertices": {"end": {"Name": "end", "Virtual": true}},
+		"Edges": [["x_store", "end"]],
+		"Templates": {"etl": {
+			"Vertices": {
+				"fetch": {"Name": "fetch", "FactoryName": "echo", "ParamsMap": {"Text": "${source}"}},
+				"store": {"Name": "store", "FactoryName": "echo", "ParamsMap": {"Num": "${num}"}}
+			},
+			"Edges": [["fetch", "store"]]
+		}},
+		"Instances": [{"Template": "etl", "Prefix": "x_", "Params": {"source": "file", "num": 3}}]
+	}`))
+
+	if err != nil {
+		t.Errorf("p2.LoadGraph() got err = %v, want nil", err)
+	}
+
+	if err := p2.Check(); err != nil {
+		t.Errorf("p2.Check() got err = %v, want nil", err)
+	}
+
+	state = NewState()
+
+	if err := p2.Run(context.Background(), state); err != nil {
+		t.Errorf("p2.Run() got err = %v, want nil", err)
+	}
+
+	if got := LoadState[string](state, "x_fetch") + LoadState[string](state, "x_store"); got != "file03" {
+		t.Errorf("got x_fetch + x_store = %s, want file03", got)
+	}
+
+	if len(p2.graph.Edges) != 2 {
+		t.Errorf("got %d edges, want 2", len(p2.graph.Edges))
+	}
+
+	// templates with nested pipelines survive dump and load
+	sub := NewPipeline()
+	sub.Register(NewElement("echo").UseFactory("echo").Params("Text", "nested"))
+
+	nestedTpl := NewTemplate()
+	nestedTpl.Register(NewElement("sub").UseNode(sub))
+
+	p4 := NewPipeline()
+	p4.RegisterFactory("echo", func() ogcore.Node { return &TEchoNode{} })
+
+	if _, err := p4.Instantiate(nestedTpl, "a_", nil); err != nil {
+		t.Fatalf("p4.Instantiate() got err = %v, want nil", err)
+	}
+
+	data, err = p4.DumpGraph()
+	if err != nil {
+		t.Fatalf("p4.DumpGraph() got err = %v, want nil", err)
+	}
+
+	p5 := NewPipeline()
+	p5.RegisterFactory("echo", func() ogcore.Node { return &TEchoNode{} })
+
+	if err := p5.LoadGraph(data); err != nil {
+		t.Fatalf("p5.LoadGraph() got err = %v, want nil", err)
+	}
+
+	if err := p5.Check(); err != nil {
+		t.Errorf("p5.Check() got err = %v, want nil", err)
+	}
+
+	state = NewState()
+
+	if err := p5.Run(context.Background(), state); err != nil {
+		t.Errorf("p5.Run() got err = %v, want nil", err)
+	}
+
+	if got := LoadState[string](state, "echo"); got != "nested0" {
+		t.Errorf("got echo = %s, want nested0", got)
+	}
+
+	if data2, err := p5.DumpGraph(); err != nil || !strings.Contains(string(data2), `"Instances"`) {
+		t.Errorf("p5.DumpGraph() got %s, err = %v, want template instances", data2, err)
+	}
+}
+
+func TestPipeline_Deterministic(t *testing.T) {
+	var trace []string
+
+	newElem := func(name string) *Element {
+		return NewElement(name).UseFn(func() error {
+			trace = append(trace, name)
+			return nil
+		})
+	}
+
+	p := NewPipeline()
+	p.Deterministic = true
+
+	start := NewElement("start").AsVirtual()
+	p.Register(start, Then(newElem("c"), newElem("b"), newElem("a").SetPriority(1))).
+		Register(newElem("d"), Rely(p.elements["b"]))
+
+	if err := p.Run(context.Background(), nil); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("got trace = %v, want %v", trace, want)
+	}
+
+	p.Seed = 42
+
+	runWithSeed := func() []string {
+		trace = nil
+
+		if err := p.Run(context.Background(), nil); err != nil {
+			t.Errorf("p.Run() got err = %v, want nil", err)
+		}
+
+		return trace
+	}
+
+	first := runWithSeed()
+
+	for range 10 {
+		if got := runWithSeed(); !reflect.DeepEqual(got, first) {
+			t.Errorf("got trace = %v, want %v", got, first)
+		}
+	}
+
+	p.Register(p.elements["a"], Rely(p.elements["d"])).Register(p.elements["d"], Rely(p.elements["a"]))
+	p.ResetPool()
+
+	if err := p.Run(context.Background(), nil); !errors.Is(err, internal.ErrUnreachable) {
+		t.Errorf("p.Run() got err = %v, want %v", err, internal.ErrUnreachable)
+	}
+
+	// extreme priorities are ordered without overflow
+	trace = nil
+
+	p = NewPipeline()
+	p.Deterministic = true
+
+	p.Register(newElem("x").SetPriority(math.MinInt)).
+		Register(newElem("y")).
+		Register(newElem("z").SetPriority(math.MaxInt))
+
+	if err := p.Run(context.Background(), nil); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	if want := []string{"z", "y", "x"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("got trace = %v, want %v", trace, want)
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	p := NewPipeline()
+
+	a := NewElement("a").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+		state.Set("x", 1)
+		return nil
+	}))
+	b := NewElement("b").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+		state.Set("y", LoadState[int](state, "x")+1)
+		return nil
+	}))
+	c := NewElement("c").UseNode(&BaseNode{})
+
+	p.Register(a, Branch(b, c))
+
+	dbg := NewDebugger(p)
+
+	if err := dbg.SetBreakpoint("b", "x == 1"); err != nil {
+		t.Errorf("dbg.SetBreakpoint() got err = %v, want nil", err)
+	}
+
+	if err := dbg.Step(); !errors.Is(err, ErrNotPaused) {
+		t.Errorf("dbg.Step() got err = %v, want %v", err, ErrNotPaused)
+	}
+
+	wait := dbg.Start(context.Background(), nil)
+
+	pause := <-dbg.Pauses()
+
+	if pause.Node != "b" {
+		t.Errorf("got pause at %s, want b", pause.Node)
+	}
+
+	vertices, _ := dbg.Vertices()
+	if want := map[string]string{"a": VertexDone, "b": VertexReady, "c": VertexTodo}; !reflect.DeepEqual(vertices, want) {
+		t.Errorf("got vertices = %v, want %v", vertices, want)
+	}
+
+	dbg.State().Set("x", 10)
+
+	if err := dbg.Step(); err != nil {
+		t.Errorf("dbg.Step() got err = %v, want nil", err)
+	}
+
+	if pause := <-dbg.Pauses(); pause.Node != "c" {
+		t.Errorf("got pause at %s, want c", pause.Node)
+	}
+
+	if err := dbg.Continue(); err != nil {
+		t.Errorf("dbg.Continue() got err = %v, want nil", err)
+	}
+
+	if err := wait(); err != nil {
+		t.Errorf("wait() got err = %v, want nil", err)
+	}
+
+	if y := LoadState[int](dbg.State(), "y"); y != 11 {
+		t.Errorf("got y = %d, want 11", y)
+	}
+
+	// condition is false, so the run is not paused
+	dbg.SetBreakpoint("b", "x > 1")
+	wait = dbg.Start(context.Background(), dbg.State())
+
+	if _, ok := <-dbg.Pauses(); ok {
+		t.Error("got pause, want none")
+	}
+
+	if err := wait(); err != nil {
+		t.Errorf("wait() got err = %v, want nil", err)
+	}
+}
+
+func TestDebugger_ServeCLI(t *testing.T) {
+	p := NewPipeline()
+	p.Register(NewElement("a").UseNode(&BaseNode{}), Then(NewElement("b").UseNode(NewFuncNode(
+		func(ctx context.Context, state ogcore.State) error {
+			if LoadState[string](state, "name") != "ograph" {
+				return errors.New("wrong name")
+			}
+			return nil
+		}))))
+
+	dbg := NewDebugger(p)
+	dbg.SetBreakpoint("*", "")
+
+	wait := dbg.Start(context.Background(), nil)
+
+	out := new(strings.Builder)
+	in := strings.NewReader("ls\nset name \"ograph\"\nget name\nclear *\nc\n")
+
+	if err := dbg.ServeCLI(in, out); err != nil {
+		t.Errorf("dbg.ServeCLI() got err = %v, want nil", err)
+	}
+
+	if err := wait(); err != nil {
+		t.Errorf("wait() got err = %v, want nil", err)
+	}
+
+	for _, want := range []string{"paused before a", "ready: a", "todo: b", "name = (string)ograph", "run finished"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got output %q, want contains %q", out.String(), want)
+		}
+	}
+}
+
+func TestPipeline_Start(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	p := NewPipeline()
+
+	var callbackCnt atomic.Int32
+	p.OnProgress = func(progress RunProgress) {
+		callbackCnt.Add(1)
+	}
+
+	a := NewElement("a").UseNode(&BaseNode{})
+	b := NewElement("b").UseFn(func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	c := NewElement("c").UseNode(&BaseNode{})
+	d := NewElement("d").UseNode(&BaseNode{})
+
+	p.Register(a, Then(b, c)).Register(d, Rely(b, c))
+
+	handle := p.Start(context.Background(), nil)
+
+	<-started
+
+	// wait for c, which is not blocked
+	for handle.Snapshot().Vertices["c"].Status != ProgressDone {
+		time.Sleep(time.Millisecond)
+	}
+
+	snapshot := handle.Snapshot()
+
+	if snapshot.Total != 4 || snapshot.Completed != 2 || snapshot.Percent != 50 {
+		t.Errorf("got completed %d/%d (%v%%), want 2/4 (50%%)", snapshot.Completed, snapshot.Total, snapshot.Percent)
+	}
+
+	for name, want := range map[string]string{"a": ProgressDone, "b": ProgressRunning, "d": ProgressPending} {
+		if got := snapshot.Vertices[name].Status; got != want {
+			t.Errorf("got %s status = %s, want %s", name, got, want)
+		}
+	}
+
+	if snapshot.Vertices["b"].Elapsed <= 0 {
+		t.Errorf("got b elapsed = %v, want more than 0", snapshot.Vertices["b"].Elapsed)
+	}
+
+	close(release)
+
+	var last RunProgress
+	for progress := range handle.Progress() {
+		last = progress
+	}
+
+	if err := handle.Wait(); err != nil {
+		t.Errorf("handle.Wait() got err = %v, want nil", err)
+	}
+
+	if last.Node != "d" || last.Percent != 100 {
+		t.Errorf("got last progress = %+v, want d with 100%%", last)
+	}
+
+	if n := callbackCnt.Load(); n != 4 {
+		t.Errorf("got OnProgress called %d times, want 4", n)
+	}
+
+	p2 := NewPipeline()
+	p2.Register(NewElement("t1").UseFactory("fake_factory"))
+
+	if err := p2.Start(context.Background(), nil).Wait(); err == nil {
+		t.Error("handle.Wait() got error = nil, want not nil")
+	}
+}
+
+type fakeClock struct {
+	now     time.Time
+	waiters []fakeWaiter
+
+	sync.Mutex
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.Lock()
+	defer clock.Unlock()
+
+	return clock.now
+}
+
+func (clock *fakeClock) After(d time.Duration) <-chan time.Time {
+	clock.Lock()
+	defer clock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	clock.waiters = append(clock.waiters, fakeWaiter{deadline: clock.now.Add(d), ch: ch})
+
+	return ch
+}
+
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.Lock()
+
+	clock.now = clock.now.Add(d)
+
+	var waiters []fakeWaiter
+
+	for _, w := range clock.waiters {
+		if w.deadline.After(clock.now) {
+			waiters = append(waiters, w)
+		} else {
+			w.ch <- clock.now
+		}
+	}
+
+	clock.waiters = waiters
+
+	clock.Unlock()
+}
+
+// waitWaiters waits until n waiters are waiting for clock.
+func (clock *fakeClock) waitWaiters(n int) {
+	for {
+		clock.Lock()
+		cnt := len(clock.waiters)
+		clock.Unlock()
+
+		if cnt >= n {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	for _, c := range []struct {
+		overlap       OverlapPolicy
+		queueLimit    int
+		wantRuns      int
+		wantSkipped   int
+		wantMaxActive int32
+	}{
+		{OverlapSkip, 0, 1, 2, 1},
+		{OverlapQueue, 0, 3, 0, 1},
+		{OverlapQueue, 1, 2, 1, 1},
+		{OverlapAllow, 0, 3, 0, 3},
+	} {
+		var active, maxActive atomic.Int32
+		release := make(chan struct{})
+
+		p := NewPipeline()
+		// singleton is shared by concurrent runs, use factory instead
+		p.Register(NewElement("n").UsePrivateFactory(func() ogcore.Node {
+			return NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+				n := active.Add(1)
+				defer active.Add(-1)
+
+				for old := maxActive.Load(); n > old && !maxActive.CompareAndSwap(old, n); old = maxActive.Load() {
+				}
+
+				<-release
+				return nil
+			})
+		}))
+
+		clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+		trigger := NewTrigger(p, Every(time.Minute))
+		trigger.Overlap = c.overlap
+		trigger.QueueLimit = c.queueLimit
+		trigger.Clock = clock
+
+		var stateCnt atomic.Int32
+		trigger.NewState = func() ogcore.State {
+			stateCnt.Add(1)
+			return NewState()
+		}
+
+		if err := trigger.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := trigger.Start(context.Background()); !errors.Is(err, ErrTriggerStarted) {
+			t.Errorf("got err = %v, want %v", err, ErrTriggerStarted)
+		}
+
+		clock.waitWaiters(1)
+
+		for i := 0; i < 3; i++ {
+			clock.Advance(time.Minute)
+			clock.waitWaiters(1)
+
+			// wait for the run started, queued runs are started later
+			for want := min(i+1, int(c.wantMaxActive)); trigger.Running() < want; {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		close(release)
+
+		for len(trigger.History()) < c.wantRuns+c.wantSkipped {
+			time.Sleep(time.Millisecond)
+		}
+
+		trigger.Stop()
+
+		var runs, skipped int
+
+		for _, run := range trigger.History() {
+			if run.Skipped {
+				skipped++
+
+				if c.overlap == OverlapQueue && !errors.Is(run.Err, ErrTriggerQueueFull) {
+					t.Errorf("got skipped run err = %v, want %v", run.Err, ErrTriggerQueueFull)
+				}
+			} else if run.Err != nil {
+				t.Errorf("got run err = %v, want nil", run.Err)
+			} else {
+				runs++
+			}
+		}
+
+		if runs != c.wantRuns || skipped != c.wantSkipped || maxActive.Load() != c.wantMaxActive {
+			t.Errorf("overlap %d: got %d runs, %d skipped, %d max active, want %d, %d, %d",
+				c.overlap, runs, skipped, maxActive.Load(), c.wantRuns, c.wantSkipped, c.wantMaxActive)
+		}
+
+		if int(stateCnt.Load()) != runs {
+			t.Errorf("got %d states created, want %d", stateCnt.Load(), runs)
+		}
+
+		firstRun := func(run TriggerRun) bool {
+			return !run.Skipped && run.ScheduledAt.Equal(clock.now.Add(-2*time.Minute))
+		}
+
+		if !slices.ContainsFunc(trigger.History(), firstRun) {
+			t.Errorf("got history %+v, want a run scheduled at %v", trigger.History(), clock.now.Add(-2*time.Minute))
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2025, 1, 3, 17, 50, 30, 0, time.UTC) // friday
+
+	for spec, want := range map[string]time.Time{
+		"*/15 9-17 * * mon-fri": time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":            time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 13 * 6":           time.Date(2025, 1, 4, 12, 0, 0, 0, time.UTC),
+		"5,55 17 * jan *":       time.Date(2025, 1, 3, 17, 55, 0, 0, time.UTC),
+		"@daily":                time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC),
+		"@every 90s":            from.Add(90 * time.Second),
+	} {
+		schedule, err := ParseCron(spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q) got err = %v", spec, err)
+			continue
+		}
+
+		if got := schedule.Next(from); !got.Equal(want) {
+			t.Errorf("ParseCron(%q).Next() got %v, want %v", spec, got, want)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * * 0 *", "5-1 * * * *", "*/0 * * * *", "@every x"} {
+		if _, err := ParseCron(spec); !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("ParseCron(%q) got err = %v, want %v", spec, err, ErrInvalidCron)
+		}
+	}
+}
+
+type TIngestNode struct {
+	BaseEventNode
+}
+
+func (node *TIngestNode) Run(ctx context.Context, state ogcore.State) error {
+	node.EmitPayload("ingest.done", state, LoadState[int](state, "rows"))
+	return nil
+}
+
+func TestEventTrigger(t *testing.T) {
+	var reports []int
+	var mu sync.Mutex
+
+	report := NewPipeline()
+	report.Register(NewElement("report").UseFactory("report"))
+	report.RegisterFactory("report", func() ogcore.Node {
+		return NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			reports = append(reports, LoadState[int](state, "rows"))
+			return nil
+		})
+	})
+
+	ingest := NewPipeline()
+	ingest.Register(NewElement("ingest").UseNode(&TIngestNode{}))
+
+	trigger := NewEventTrigger(report, ingest, "^ingest\\.done$")
+	trigger.Overlap = OverlapQueue
+	trigger.StateFrom = func(event string, obj ogcore.State) ogcore.State {
+		rows, ok := PayloadOf[int](obj)
+		if !ok || rows == 0 {
+			return nil
+		}
+
+		state := NewState()
+		state.Set("rows", rows)
+		return state
+	}
+
+	if err := trigger.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rows := range []int{1, 0, 2, 3} {
+		state := NewState()
+		state.Set("rows", rows)
+
+		if err := ingest.Run(context.Background(), state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for len(trigger.History()) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	trigger.Stop()
+
+	mu.Lock()
+	slices.Sort(reports)
+	if !slices.Equal(reports, []int{1, 2, 3}) {
+		t.Errorf("got reports %v, want [1 2 3]", reports)
+	}
+	reports = nil
+	mu.Unlock()
+
+	// debounce events on global bus
+	clock := &fakeClock{now: time.Now()}
+
+	debounced := NewEventTrigger(report, global.EventBus, "^rows$")
+	debounced.Debounce = time.Second
+	debounced.Clock = clock
+	debounced.StateFrom = trigger.StateFrom
+
+	if err := debounced.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for rows := 1; rows <= 3; rows++ {
+		EmitPayload(global.EventBus, "rows", NewState(), rows)
+	}
+
+	clock.waitWaiters(3)
+	clock.Advance(time.Second)
+
+	for len(debounced.History()) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	debounced.Stop()
+
+	if history := debounced.History(); len(history) != 1 || history[0].Event != "rows" || history[0].Err != nil {
+		t.Errorf("got history %+v, want one run of rows event", history)
+	}
+
+	if !slices.Equal(reports, []int{3}) {
+		t.Errorf("got reports %v, want [3]", reports)
+	}
+}
+
+func TestPipeline_Journal(t *testing.T) {
+	path := t.TempDir() + "/journal.log"
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runCnt map[string]int
+
+	newPipeline := func(journal *Journal, crash bool) *Pipeline {
+		runCnt = make(map[string]int)
+
+		p := NewPipeline()
+		p.Journal = journal
+
+		a := NewElement("a").UseFn(func() error {
+			runCnt["a"]++
+			return nil
+		})
+
+		b := NewElement("b").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			runCnt["b"]++
+			state.Set("x", 1)
+			UpdateState(state, "y", func(y float64) float64 { return y + 0.5 })
+
+			batchSetter, ok := state.(ogcore.BatchSetter)
+			if !ok {
+				return errors.New("journal state is not BatchSetter")
+			}
+
+			batchSetter.SetBatch(map[any]any{"z": "batch", "w": 2})
+			return nil
+		}))
+
+		c := NewElement("c").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			runCnt["c"]++
+
+			if crash {
+				// writes after crash are lost
+				journal.Close()
+				return errors.New("crash")
+			}
+
+			if LoadState[int](state, "x") != 1 || LoadState[float64](state, "y") != 0.5 || LoadState[string](state, "input") != "hi" {
+				return errors.New("state is not restored")
+			}
+
+			return nil
+		}))
+
+		return p.Register(a, Then(b)).Register(c, Rely(b))
+	}
+
+	state := NewState()
+	state.Set("input", "hi")
+
+	newPipeline(journal, true).Run(context.Background(), state)
+
+	journal, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer journal.Close()
+
+	runs, err := journal.Incomplete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 1 {
+		t.Fatalf("got %d incomplete runs, want 1", len(runs))
+	}
+
+	run := runs[0]
+
+	if !reflect.DeepEqual(run.Completed, []string{"a", "b"}) || !reflect.DeepEqual(run.Running, []string{"c"}) {
+		t.Errorf("got completed %v, running %v, want [a b], [c]", run.Completed, run.Running)
+	}
+
+	if x, ok := run.State["x"].(int); !ok || x != 1 {
+		t.Errorf("got restored x = (%T)%v, want (int)1", run.State["x"], run.State["x"])
+	}
+
+	if run.State["z"] != "batch" || run.State["w"] != 2 {
+		t.Errorf("got restored z = %v, w = %v, want batch, 2", run.State["z"], run.State["w"])
+	}
+
+	if data, _ := os.ReadFile(path); strings.Count(string(data), `"type":"set_batch"`) != 1 {
+		t.Errorf("got journal %s, want one set_batch record", data)
+	}
+
+	// states which can't be snapshot are rejected
+	var customState struct{ ogcore.State }
+	customState.State = NewState()
+
+	if err := newPipeline(journal, false).Run(context.Background(), customState); !errors.Is(err, ErrJournalState) {
+		t.Errorf("Run() got err = %v, want %v", err, ErrJournalState)
+	}
+
+	p := newPipeline(journal, false)
+	p.elements["c"].AsNonIdempotent()
+
+	if err := p.Resume(context.Background(), run); !errors.Is(err, ErrNotIdempotent) {
+		t.Errorf("Resume() got err = %v, want %v", err, ErrNotIdempotent)
+	}
+
+	if err := newPipeline(nil, false).Resume(context.Background(), run); !errors.Is(err, ErrJournalNotSet) {
+		t.Errorf("Resume() got err = %v, want %v", err, ErrJournalNotSet)
+	}
+
+	if err := newPipeline(journal, false).Resume(context.Background(), run); err != nil {
+		t.Errorf("Resume() got err = %v", err)
+	}
+
+	if !reflect.DeepEqual(runCnt, map[string]int{"c": 1}) {
+		t.Errorf("got node run count %v, want only c run once", runCnt)
+	}
+
+	if runs, err := journal.Incomplete(); err != nil || len(runs) != 0 {
+		t.Errorf("got incomplete runs %v, err = %v, want none", runs, err)
+	}
+
+	if err := journal.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("got journal %s after compact, want empty", data)
+	}
+}
+
+type ctxTxNode struct {
+	BaseNode
+
+	log       *[]string
+	lock      *sync.Mutex
+	commitErr error
+}
+
+func (tx *ctxTxNode) record(action string) {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	*tx.log = append(*tx.log, action+" "+tx.Name())
+}
+
+func (tx *ctxTxNode) Run(ctx context.Context, state ogcore.State) error {
+	tx.record("run")
+	return nil
+}
+
+func (tx *ctxTxNode) CommitContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	tx.record("commit")
+	return tx.commitErr
+}
+
+func (tx *ctxTxNode) RollbackContext(ctx context.Context) error {
+	tx.record("rollback")
+	return nil
+}
+
+func TestPipeline_ContextTransaction(t *testing.T) {
+	var log []string
+	var lock sync.Mutex
+
+	newTx := func(name string, commitErr error) *Element {
+		return NewElement(name).UsePrivateFactory(func() ogcore.Node {
+			return &ctxTxNode{log: &log, lock: &lock, commitErr: commitErr}
+		})
+	}
+
+	newPipeline := func(last *Element) *Pipeline {
+		inner := NewPipeline()
+		inner.Register(newTx("inner", nil))
+
+		p := NewPipeline()
+		p.Register(newTx("outer", nil), Branch(NewElement("sub").UseNode(inner), last))
+
+		return p
+	}
+
+	// transactions of nested pipeline are committed with outer run
+	log = nil
+
+	if err := newPipeline(NewElement("last").UseFn(func() error { return nil })).Run(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"run outer", "run inner", "commit outer", "commit inner"}; !slices.Equal(log, want) {
+		t.Errorf("want %v, got %v", want, log)
+	}
+
+	// failure of outer run rolls back transactions of nested pipeline
+	log = nil
+
+	if err := newPipeline(NewElement("last").UseFn(func() error { return errors.New("last failed") })).Run(context.TODO(), nil); err == nil {
+		t.Fatal("expect error")
+	}
+
+	if want := []string{"run outer", "run inner", "rollback inner", "rollback outer"}; !slices.Equal(log, want) {
+		t.Errorf("want %v, got %v", want, log)
+	}
+
+	// commit failure is returned in run error, it and the rest are rolled back
+	log = nil
+
+	err := newPipeline(newTx("last", errors.New("disk full"))).Run(context.TODO(), nil)
+	if !errors.Is(err, ErrCommitFailed) {
+		t.Fatalf("expect commit failed, got: %v", err)
+	}
+
+	if want := []string{"run outer", "run inner", "run last", "commit outer", "commit inner", "commit last", "rollback last"}; !slices.Equal(log, want) {
+		t.Errorf("want %v, got %v", want, log)
+	}
+
+	// commit is not affected by canceled ctx of run
+	log = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := NewPipeline()
+	p.Register(newTx("tx", nil), Then(NewElement("cancel").UseFn(func() error { cancel(); return nil })))
+
+	if err := p.Run(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"run tx", "commit tx"}; !slices.Equal(log, want) {
+		t.Errorf("want %v, got %v", want, log)
+	}
+}
+
+func TestPipeline_LateNestedTransaction(t *testing.T) {
+	var log []string
+	var lock sync.Mutex
+
+	for _, c := range []struct {
+		outerErr error
+		want     []string
+	}{
+		{nil, []string{"run inner", "commit inner"}},
+		{errors.New("outer failed"), []string{"run inner", "rollback inner"}},
+	} {
+		log = nil
+
+		release := make(chan struct{})
+		innerDone := make(chan error, 1)
+
+		inner := NewPipeline()
+		inner.Register(NewElement("wait").UseFn(func() error { <-release; return nil }),
+			Then(NewElement("inner").UsePrivateFactory(func() ogcore.Node {
+				return &ctxTxNode{log: &log, lock: &lock}
+			})))
+
+		// nested pipeline is left running after the outer run ends
+		p := NewPipeline()
+		p.Register(NewElement("spawn").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+			go func() { innerDone <- inner.Run(ctx, state) }()
+			return nil
+		})), Then(NewElement("last").UseFn(func() error { return c.outerErr })))
+
+		if err := p.Run(context.TODO(), nil); !errors.Is(err, c.outerErr) {
+			t.Fatalf("expect outer error %v, got: %v", c.outerErr, err)
+		}
+
+		close(release)
+
+		select {
+		case err := <-innerDone:
+			if err != nil {
+				t.Errorf("expect late nested pipeline succeeded, got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("late nested pipeline not returned")
+		}
+
+		// transactions of late nested pipeline follow the ended outer run
+		lock.Lock()
+		if !slices.Equal(log, c.want) {
+			t.Errorf("want %v, got %v", c.want, log)
+		}
+		lock.Unlock()
+	}
+}
+
+func TestPipeline_NestedWorkerRelease(t *testing.T) {
+	var built atomic.Int32
+
+	inner := NewPipeline()
+	inner.SetPoolCache(1, false)
+	inner.Register(NewElement("count").UsePrivateFactory(func() ogcore.Node {
+		built.Add(1)
+		return NewFuncNode(func(ctx context.Context, state ogcore.State) error { return nil })
+	}))
+
+	// worker of nested pipeline without transactions is released after each run
+	p := NewPipeline()
+	p.Register(NewElement("loop").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+		for i := 0; i < 100; i++ {
+			if err := inner.Run(ctx, state); err != nil {
+				return err
+			}
+		}
+		return nil
+	})))
+
+	if err := p.Run(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := built.Load(); n != 1 {
+		t.Errorf("expect 1 worker of nested pipeline built, got %d", n)
+	}
+}
+
+func TestPipeline_LifecycleSubscription(t *testing.T) {
+	inner := NewPipeline()
+	inner.Register(NewElement("inner_t").UseFn(func() error { return nil }))
+
+	p := NewPipeline()
+	p.Register(NewElement("sub").UseNode(inner))
+
+	var nodeEvents atomic.Int32
+
+	cancelCustom, _ := p.Subscribe(func(event string, obj ogcore.State) bool { return true }, eventd.On(`^custom$`))
+	defer cancelCustom()
+
+	if n := p.lifecycleSubs.Load(); n != 0 {
+		t.Errorf("got %d lifecycle subscriptions, want 0", n)
+	}
+
+	cancel, _ := SubscribePayload(p, func(event string, state ogcore.State, e *LifecycleEvent) bool {
+		if e.Node == "inner_t" {
+			nodeEvents.Add(1)
+		}
+		return true
+	}, eventd.On(`^node\.`))
+
+	if err := p.Run(context.Background(), nil); err != nil {
+		t.Errorf("p.Run() got err = %v, want nil", err)
+	}
+
+	// node.start and node.end of nested node are forwarded
+	if n := nodeEvents.Load(); n != 2 {
+		t.Errorf("got %d events of nested node, want 2", n)
+	}
+
+	cancel()
+	cancel()
+
+	if n := p.lifecycleSubs.Load(); n != 0 {
+		t.Errorf("got %d lifecycle subscriptions after cancel, want 0", n)
+	}
+
+	if err := p.Run(context.Background(), nil); err != nil || nodeEvents.Load() != 2 {
+		t.Errorf("p.Run() got err = %v, events = %d, want no more events", err, nodeEvents.Load())
+	}
+
+	// events emitted by wrappers are gated the same way
+	var retries atomic.Int32
+
+	p2 := NewPipeline()
+	p2.Register(NewElement("retry").UseNode(NewFuncNode(func(ctx context.Context, state ogcore.State) error {
+		EmitLifecycle(ctx, p2.eventBus, EventNodeRetry, state, &LifecycleEvent{Node: "retry"})
+		return nil
+	})))
+
+	cancelRetry, _ := p2.eventBus.Subscribe(func(event string, obj ogcore.State) bool {
+		retries.Add(1)
+		return true
+	}, eventd.On(`^node\.retry$`))
+	defer cancelRetry()
+
+	if err := p2.Run(context.Background(), nil); err != nil || retries.Load() != 0 {
+		t.Errorf("p2.Run() got err = %v, retries = %d, want no events without lifecycle subscription", err, retries.Load())
+	}
+
+	cancelRetry2, _ := p2.Subscribe(func(event string, obj ogcore.State) bool { return true }, eventd.On(`^node\.retry$`))
+	defer cancelRetry2()
+
+	if n := p2.lifecycleSubs.Load(); n != 1 {
+		t.Errorf("got %d lifecycle subscriptions of node.retry, want 1", n)
+	}
+
+	if err := p2.Run(context.Background(), nil); err != nil || retries.Load() != 1 {
+		t.Errorf("p2.Run() got err = %v, retries = %d, want 1", err, retries.Load())
+	}
+}
@@ -0,0 +1,129 @@
+package ograph
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/symphony09/ograph/internal"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+const (
+	ProgressPending = internal.ProgressPending
+	ProgressQueued  = internal.ProgressQueued
+	ProgressRunning = internal.ProgressRunning
+	ProgressDone    = internal.ProgressDone
+	ProgressFailed  = internal.ProgressFailed
+)
+
+type RunSnapshot = internal.RunSnapshot
+type RunProgress = internal.RunProgress
+
+// RunHandle is a running pipeline, its methods are safe to call while the run is going on.
+type RunHandle struct {
+	params   *internal.WorkParams
+	progress *internal.Progress
+	updates  chan RunProgress
+
+	done chan struct{}
+	err  error
+}
+
+// Start runs pipeline in background and returns a handle to watch and control the run.
+func (pipeline *Pipeline) Start(ctx context.Context, state ogcore.State) *RunHandle {
+	handle := &RunHandle{
+		updates: make(chan RunProgress, 1),
+		done:    make(chan struct{}),
+	}
+
+	newCtx, newState, worker, params, afterRun, err := pipeline.prepare(ctx, state)
+	if err != nil {
+		handle.err = err
+		handle.progress = internal.NewProgress(nil, nil)
+		close(handle.updates)
+		close(handle.done)
+		return handle
+	}
+
+	handle.params = params
+	params.ContinueCond = sync.NewCond(&sync.Mutex{})
+
+	onProgress := pipeline.OnProgress
+
+	handle.progress = internal.NewProgress(slices.Collect(maps.Keys(pipeline.graph.Vertices)), func(progress RunProgress) {
+		select {
+		case handle.updates <- progress:
+		default:
+			// drop the stale one, only the latest progress is kept
+			select {
+			case <-handle.updates:
+			default:
+			}
+
+			select {
+			case handle.updates <- progress:
+			default:
+			}
+		}
+
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	})
+	params.Progress = handle.progress
+
+	go func() {
+		defer close(handle.done)
+		defer close(handle.updates)
+		defer afterRun()
+
+		handle.err = worker.Work(newCtx, newState, params)
+	}()
+
+	return handle
+}
+
+// Wait blocks until the run finished and returns its error.
+func (handle *RunHandle) Wait() error {
+	<-handle.done
+	return handle.err
+}
+
+func (handle *RunHandle) Done() <-chan struct{} {
+	return handle.done
+}
+
+// Pause stops starting new nodes, nodes which are running are not affected.
+func (handle *RunHandle) Pause() {
+	if handle.params == nil {
+		return
+	}
+
+	handle.params.ContinueCond.L.Lock()
+	handle.params.Pause = true
+	handle.params.ContinueCond.L.Unlock()
+}
+
+func (handle *RunHandle) Continue() {
+	if handle.params == nil {
+		return
+	}
+
+	handle.params.ContinueCond.L.Lock()
+	handle.params.Pause = false
+	handle.params.ContinueCond.L.Unlock()
+	handle.params.ContinueCond.Broadcast()
+}
+
+// Snapshot returns status and elapsed time of each node, and how much of the run is completed.
+func (handle *RunHandle) Snapshot() RunSnapshot {
+	return handle.progress.Snapshot()
+}
+
+// Progress returns channel which receives progress when a node is completed, it's closed when the run finished.
+// Only the latest progress is kept if the receiver can't keep up.
+func (handle *RunHandle) Progress() <-chan RunProgress {
+	return handle.updates
+}
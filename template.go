@@ -0,0 +1,295 @@
+package ograph
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	"github.com/symphony09/ograph/internal"
+)
+
+var ErrTemplateNotFound error = errors.New("template not found")
+var ErrTemplateParamNotSet error = errors.New("template param not set")
+
+var placeholderRegexp = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Template is a reusable graph fragment, which is defined like a pipeline and
+// can be instantiated into pipelines many times.
+// String values in element params can contain placeholders like ${key},
+// they are filled with concrete params on instantiation.
+type Template struct {
+	// Name is key of template in dumped graph of pipelines instantiating it, a name is generated if it's empty.
+	Name string
+
+	fragment *Pipeline
+}
+
+func (tpl *Template) Register(e *Element, ops ...Op) *Template {
+	tpl.fragment.Register(e, ops...)
+	return tpl
+}
+
+// TemplateInstance holds elements instantiated from template, keyed by their names in template.
+type TemplateInstance struct {
+	Prefix   string
+	Elements map[string]*Element
+
+	// Entries are elements without dependencies in template, Exits are elements without next ones.
+	Entries []*Element
+	Exits   []*Element
+}
+
+func (instance *TemplateInstance) Elem(name string) *Element {
+	return instance.Elements[name]
+}
+
+// Instantiate registers a copy of template elements and edges into pipeline,
+// element names are prefixed by prefix, and placeholders in params are filled by params.
+func (pipeline *Pipeline) Instantiate(tpl *Template, prefix string, params map[string]any) (*TemplateInstance, error) {
+	instance := &TemplateInstance{
+		Prefix:   prefix,
+		Elements: make(map[string]*Element),
+	}
+
+	names := slices.Sorted(maps.Keys(tpl.fragment.graph.Vertices))
+
+	for _, name := range names {
+		if pipeline.elements[prefix+name] != nil {
+			return nil, fmt.Errorf("%w, name: %s", ErrElementExists, prefix+name)
+		}
+	}
+
+	elements, err := tpl.elements(prefix, params)
+	if err != nil {
+		return nil, err
+	}
+
+	instance.Elements = elements
+
+	for _, name := range names {
+		pipeline.Register(instance.Elements[name])
+	}
+
+	for _, name := range names {
+		v := tpl.fragment.graph.Vertices[name]
+
+		for _, next := range v.Next {
+			pipeline.connect(instance.Elements[name], instance.Elements[next.Name])
+		}
+
+		if len(v.Dependencies) == 0 {
+			instance.Entries = append(instance.Entries, instance.Elements[name])
+		}
+
+		if len(v.Next) == 0 {
+			instance.Exits = append(instance.Exits, instance.Elements[name])
+		}
+	}
+
+	pipeline.instances = append(pipeline.instances, templateRef{tpl: tpl, prefix: prefix, params: cloneParams(params)})
+
+	pipeline.ResetPool()
+
+	return instance, nil
+}
+
+// elements returns copies of template elements, with prefixed names and filled params, keyed by names in template.
+func (tpl *Template) elements(prefix string, params map[string]any) (map[string]*Element, error) {
+	elements := make(map[string]*Element, len(tpl.fragment.graph.Vertices))
+
+	for name, v := range tpl.fragment.graph.Vertices {
+		elem := v.Elem.Clone()
+		elem.Name = prefix + name
+
+		if err := elem.fillPlaceholders(params); err != nil {
+			return nil, fmt.Errorf("can't instantiate element %s, err: %w", elem.Name, err)
+		}
+
+		elements[name] = elem
+	}
+
+	return elements, nil
+}
+
+// templateRef records an instantiation of template, so that it's dumped as template instance.
+type templateRef struct {
+	tpl    *Template
+	prefix string
+	params map[string]any
+}
+
+// unchanged reports whether elements and edges instantiated by ref are still in pipeline as they were,
+// otherwise they are dumped as normal elements.
+func (ref templateRef) unchanged(pipeline *Pipeline) bool {
+	elements, err := ref.tpl.elements(ref.prefix, ref.params)
+	if err != nil {
+		return false
+	}
+
+	for name, elem := range elements {
+		current := pipeline.elements[elem.Name]
+		if current == nil || pipeline.graph.Vertices[elem.Name] == nil {
+			return false
+		}
+
+		if !sameElement(elem, current) {
+			return false
+		}
+
+		for _, next := range ref.tpl.fragment.graph.Vertices[name].Next {
+			if !slices.ContainsFunc(pipeline.graph.Vertices[elem.Name].Next, func(v *internal.GraphVertex[*Element]) bool {
+				return v.Name == ref.prefix+next.Name
+			}) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// sameElement reports whether elements are dumped the same, including graphs of nested pipelines.
+func sameElement(want, got *Element) bool {
+	if !sameJSON(want, got) {
+		return false
+	}
+
+	wantSub, _ := want.Singleton.(*Pipeline)
+	gotSub, _ := got.Singleton.(*Pipeline)
+
+	if wantSub == nil || gotSub == nil {
+		return wantSub == gotSub
+	}
+
+	return sameJSON(wantSub.marshaler(), gotSub.marshaler())
+}
+
+func sameJSON(want, got any) bool {
+	wantData, err1 := json.Marshal(want)
+	gotData, err2 := json.Marshal(got)
+
+	return err1 == nil && err2 == nil && bytes.Equal(wantData, gotData)
+}
+
+// dumpTemplates moves elements of unchanged template instances of pipeline from marshaler into template refs.
+func (pipeline *Pipeline) dumpTemplates(marshaler *internal.GraphMarshaler[*Element]) {
+	names := make(map[*Template]string)
+	used := make(map[string]bool)
+
+	for _, ref := range pipeline.instances {
+		if !ref.unchanged(pipeline) {
+			continue
+		}
+
+		name, ok := names[ref.tpl]
+
+		if !ok {
+			name = ref.tpl.Name
+
+			for i := 1; name == "" || used[name]; i++ {
+				name = fmt.Sprintf("%s_%d", cmp.Or(ref.tpl.Name, "template"), i)
+			}
+
+			names[ref.tpl], used[name] = name, true
+
+			if marshaler.Templates == nil {
+				marshaler.Templates = make(map[string]*internal.GraphMarshaler[*Element])
+			}
+
+			marshaler.Templates[name] = ref.tpl.fragment.marshaler()
+		}
+
+		for vertexName := range ref.tpl.fragment.graph.Vertices {
+			delete(marshaler.Vertices, ref.prefix+vertexName)
+			delete(marshaler.SubGraphs, ref.prefix+vertexName)
+		}
+
+		marshaler.Instances = append(marshaler.Instances, internal.TemplateRef{
+			Template: name,
+			Prefix:   ref.prefix,
+			Params:   ref.params,
+		})
+	}
+}
+
+func (e *Element) fillPlaceholders(params map[string]any) error {
+	for key, val := range e.ParamsMap {
+		if newVal, err := fillPlaceholders(val, params); err != nil {
+			return err
+		} else {
+			e.ParamsMap[key] = newVal
+		}
+	}
+
+	for _, subElem := range e.SubElements {
+		if err := subElem.fillPlaceholders(params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fillPlaceholders(val any, params map[string]any) (any, error) {
+	switch v := val.(type) {
+	case string:
+		// whole string is a placeholder, use param value as it is
+		if match := placeholderRegexp.FindStringSubmatch(v); match != nil && match[0] == v {
+			if param, ok := params[match[1]]; ok {
+				return param, nil
+			} else {
+				return nil, fmt.Errorf("%w, key: %s", ErrTemplateParamNotSet, match[1])
+			}
+		}
+
+		var err error
+
+		filled := placeholderRegexp.ReplaceAllStringFunc(v, func(s string) string {
+			key := placeholderRegexp.FindStringSubmatch(s)[1]
+
+			if param, ok := params[key]; ok {
+				return fmt.Sprint(param)
+			} else {
+				err = fmt.Errorf("%w, key: %s", ErrTemplateParamNotSet, key)
+				return s
+			}
+		})
+
+		return filled, err
+	case map[string]any:
+		ret := make(map[string]any, len(v))
+
+		for key, subVal := range v {
+			if newVal, err := fillPlaceholders(subVal, params); err != nil {
+				return nil, err
+			} else {
+				ret[key] = newVal
+			}
+		}
+
+		return ret, nil
+	case []any:
+		ret := make([]any, 0, len(v))
+
+		for _, subVal := range v {
+			if newVal, err := fillPlaceholders(subVal, params); err != nil {
+				return nil, err
+			} else {
+				ret = append(ret, newVal)
+			}
+		}
+
+		return ret, nil
+	default:
+		return val, nil
+	}
+}
+
+func NewTemplate() *Template {
+	return &Template{fragment: NewPipeline()}
+}
@@ -0,0 +1,343 @@
+package ograph
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/symphony09/ograph/internal"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+var ErrInvalidCron error = internal.ErrInvalidCron
+var ErrTriggerStarted error = errors.New("trigger is already started")
+var ErrTriggerQueueFull error = errors.New("trigger queue is full")
+
+const DefaultHistoryLimit = 100
+const DefaultQueueLimit = 100
+
+// OverlapPolicy decides what to do if previous runs are still going on when it's time to run.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the run, the skip is recorded in history.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue starts the run after previous runs finished, runs in queue are dropped when trigger stops.
+	// Runs over the queue limit are skipped, they are recorded in history with ErrTriggerQueueFull.
+	OverlapQueue
+	// OverlapAllow starts the run concurrently.
+	OverlapAllow
+)
+
+// Schedule returns the next time to run after t, zero time means no more runs.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// ParseCron parses schedule of standard cron expression like "*/5 9-17 * * mon-fri",
+// descriptors like @daily and "@every 1m30s" are supported.
+func ParseCron(spec string) (Schedule, error) {
+	if interval, ok := strings.CutPrefix(strings.TrimSpace(spec), "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || d <= 0 {
+			return nil, errors.Join(ErrInvalidCron, err)
+		}
+
+		return Every(d), nil
+	}
+
+	schedule, err := internal.ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// Every returns schedule of fixed interval.
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule(interval)
+}
+
+type intervalSchedule time.Duration
+
+func (interval intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(interval))
+}
+
+// Clock provides time to trigger, it can be replaced to test triggers without real waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// TriggerRun is a run started by trigger, times are taken from clock of trigger.
+type TriggerRun struct {
+	// ScheduledAt is the time in schedule with jitter excluded, or the time event triggered the run.
+	ScheduledAt time.Time
+	StartTime   time.Time
+	EndTime     time.Time
+	Err         error
+
+	// Event is the event which triggered the run, it's empty for schedules.
+	Event string
+
+	// Skipped is true if the run is skipped by OverlapSkip.
+	Skipped bool
+}
+
+// Trigger runs pipeline on schedule until it's stopped.
+type Trigger struct {
+	Pipeline *Pipeline
+	Schedule Schedule
+
+	Overlap OverlapPolicy
+
+	// QueueLimit is the max number of queued runs for OverlapQueue, DefaultQueueLimit is used if it's not positive.
+	QueueLimit int
+
+	// Jitter delays each run by a random duration in [0, Jitter).
+	Jitter time.Duration
+
+	// NewState creates state for each run, NewState() is used if it's nil.
+	NewState func() ogcore.State
+
+	// Clock is used to wait for schedule, real time is used if it's nil.
+	Clock Clock
+
+	// HistoryLimit is the max number of runs kept in history, DefaultHistoryLimit is used if it's not positive.
+	HistoryLimit int
+
+	triggerRunner
+}
+
+// Start starts to run pipeline on schedule in background, runs are canceled when ctx is done.
+func (trigger *Trigger) Start(ctx context.Context) error {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if trigger.cancel != nil {
+		return ErrTriggerStarted
+	}
+
+	if trigger.Clock == nil {
+		trigger.Clock = realClock{}
+	}
+
+	trigger.setup(ctx, trigger.Pipeline, trigger.Clock, trigger.NewState, trigger.HistoryLimit)
+
+	trigger.limit = 1
+	if trigger.Overlap == OverlapAllow {
+		trigger.limit = 0
+	}
+	trigger.overlap, trigger.queueLimit = trigger.Overlap, trigger.QueueLimit
+
+	go trigger.loop(trigger.stopCtx, trigger.done)
+
+	return nil
+}
+
+func (trigger *Trigger) loop(stopCtx context.Context, done chan struct{}) {
+	defer close(done)
+
+	clock := trigger.Clock
+
+	now := clock.Now()
+	next := trigger.Schedule.Next(now)
+
+	for !next.IsZero() {
+		wait := next.Sub(now)
+
+		if trigger.Jitter > 0 {
+			wait += rand.N(trigger.Jitter)
+		}
+
+		select {
+		case <-stopCtx.Done():
+			return
+		case <-clock.After(wait):
+		}
+
+		trigger.fire(pendingRun{ScheduledAt: next})
+
+		// runs missed because of a long wait are not made up
+		now = clock.Now()
+		if next = trigger.Schedule.Next(next); next.Before(now) {
+			next = trigger.Schedule.Next(now)
+		}
+	}
+}
+
+type pendingRun struct {
+	ScheduledAt time.Time
+	Event       string
+	State       ogcore.State
+}
+
+// triggerRunner runs pipeline for triggers, it limits concurrent runs and keeps history of runs.
+type triggerRunner struct {
+	pipeline     *Pipeline
+	clock        Clock
+	newState     func() ogcore.State
+	historyLimit int
+
+	// limit is the max number of concurrent runs, 0 means no limit. overlap decides what to do if it's reached.
+	limit      int
+	overlap    OverlapPolicy
+	queueLimit int
+
+	runCtx  context.Context
+	stopCtx context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	running int
+	queue   []pendingRun
+	history []TriggerRun
+	wg      sync.WaitGroup
+
+	sync.Mutex
+}
+
+// setup prepares runner to start, it must be called with lock held.
+func (runner *triggerRunner) setup(ctx context.Context, pipeline *Pipeline, clock Clock, newState func() ogcore.State, historyLimit int) {
+	runner.pipeline, runner.clock, runner.newState, runner.historyLimit = pipeline, clock, newState, historyLimit
+
+	runner.runCtx = ctx
+	runner.stopCtx, runner.cancel = context.WithCancel(ctx)
+	runner.done = make(chan struct{})
+}
+
+// Stop stops the trigger and drops queued runs, it waits for running runs to finish.
+func (runner *triggerRunner) Stop() {
+	runner.Lock()
+	cancel, done := runner.cancel, runner.done
+	runner.cancel, runner.queue = nil, nil
+	runner.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+
+	runner.wg.Wait()
+}
+
+// History returns finished and skipped runs, from the oldest to the latest.
+func (runner *triggerRunner) History() []TriggerRun {
+	runner.Lock()
+	defer runner.Unlock()
+
+	return slices.Clone(runner.history)
+}
+
+// Running returns number of running runs.
+func (runner *triggerRunner) Running() int {
+	runner.Lock()
+	defer runner.Unlock()
+
+	return runner.running
+}
+
+func (runner *triggerRunner) fire(run pendingRun) {
+	runner.Lock()
+	defer runner.Unlock()
+
+	if runner.stopCtx.Err() != nil {
+		return
+	}
+
+	if runner.limit > 0 && runner.running >= runner.limit {
+		switch runner.overlap {
+		case OverlapSkip:
+			runner.record(TriggerRun{ScheduledAt: run.ScheduledAt, Event: run.Event, Skipped: true})
+			return
+		case OverlapQueue:
+			queueLimit := runner.queueLimit
+			if queueLimit <= 0 {
+				queueLimit = DefaultQueueLimit
+			}
+
+			if len(runner.queue) >= queueLimit {
+				runner.record(TriggerRun{ScheduledAt: run.ScheduledAt, Event: run.Event, Err: ErrTriggerQueueFull, Skipped: true})
+				return
+			}
+
+			runner.queue = append(runner.queue, run)
+			return
+		}
+	}
+
+	runner.launch(run)
+}
+
+// launch starts a run in background, it must be called with lock held.
+func (runner *triggerRunner) launch(pending pendingRun) {
+	runner.running++
+	runner.wg.Add(1)
+
+	go func() {
+		defer runner.wg.Done()
+
+		state := pending.State
+
+		if state == nil && runner.newState != nil {
+			state = runner.newState()
+		} else if state == nil {
+			state = NewState()
+		}
+
+		run := TriggerRun{ScheduledAt: pending.ScheduledAt, Event: pending.Event, StartTime: runner.clock.Now()}
+		run.Err = runner.pipeline.Run(runner.runCtx, state)
+		run.EndTime = runner.clock.Now()
+
+		runner.Lock()
+		defer runner.Unlock()
+
+		runner.running--
+		runner.record(run)
+
+		if len(runner.queue) > 0 && runner.stopCtx.Err() == nil {
+			next := runner.queue[0]
+			runner.queue = runner.queue[1:]
+
+			runner.launch(next)
+		}
+	}()
+}
+
+func (runner *triggerRunner) record(run TriggerRun) {
+	limit := runner.historyLimit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+
+	runner.history = append(runner.history, run)
+
+	if len(runner.history) > limit {
+		runner.history = slices.Delete(runner.history, 0, len(runner.history)-limit)
+	}
+}
+
+func NewTrigger(pipeline *Pipeline, schedule Schedule) *Trigger {
+	return &Trigger{
+		Pipeline: pipeline,
+		Schedule: schedule,
+	}
+}
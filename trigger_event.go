@@ -0,0 +1,155 @@
+package ograph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/symphony09/eventd"
+	"github.com/symphony09/ograph/ogcore"
+)
+
+// EventSource is where events come from, Pipeline and eventd.EventBus (e.g. global.EventBus) are both event sources.
+type EventSource interface {
+	Subscribe(callback eventd.CallBack[ogcore.State], ops ...eventd.Op) (cancel func(), err error)
+}
+
+// EventTrigger runs pipeline when events matching Pattern are emitted on Source, until it's stopped.
+type EventTrigger struct {
+	Pipeline *Pipeline
+	Source   EventSource
+
+	// Pattern is regular expression of events, as in eventd.On.
+	Pattern string
+
+	// StateFrom derives state of the run from event, obj is the object emitted with event, see PayloadOf.
+	// The run is skipped if it returns nil. NewState() is used if StateFrom is nil.
+	StateFrom func(event string, obj ogcore.State) ogcore.State
+
+	// Debounce delays the run until no more events come for the duration, only the last event starts a run.
+	Debounce time.Duration
+
+	// MaxConcurrent is the max number of concurrent runs, 1 is used if it's not positive.
+	// Overlap decides what to do with event if it's reached, OverlapAllow removes the limit.
+	MaxConcurrent int
+	Overlap       OverlapPolicy
+
+	// QueueLimit is the max number of queued runs for OverlapQueue, DefaultQueueLimit is used if it's not positive.
+	QueueLimit int
+
+	// Clock is used to wait for debounce, real time is used if it's nil.
+	Clock Clock
+
+	// HistoryLimit is the max number of runs kept in history, DefaultHistoryLimit is used if it's not positive.
+	HistoryLimit int
+
+	debounces   *sync.WaitGroup
+	debounceSeq uint64
+
+	triggerRunner
+}
+
+// Start subscribes events and runs pipeline in background, runs are canceled when ctx is done.
+func (trigger *EventTrigger) Start(ctx context.Context) error {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if trigger.cancel != nil {
+		return ErrTriggerStarted
+	}
+
+	if trigger.Clock == nil {
+		trigger.Clock = realClock{}
+	}
+
+	trigger.setup(ctx, trigger.Pipeline, trigger.Clock, nil, trigger.HistoryLimit)
+
+	trigger.limit = max(trigger.MaxConcurrent, 1)
+	if trigger.Overlap == OverlapAllow {
+		trigger.limit = 0
+	}
+	trigger.overlap, trigger.queueLimit = trigger.Overlap, trigger.QueueLimit
+
+	unsubscribe, err := trigger.Source.Subscribe(trigger.onEvent, eventd.On(trigger.Pattern))
+	if err != nil {
+		trigger.cancel()
+		trigger.cancel = nil
+		return err
+	}
+
+	var debounces sync.WaitGroup
+	trigger.debounces = &debounces
+
+	stopCtx, done := trigger.stopCtx, trigger.done
+
+	go func() {
+		defer close(done)
+
+		<-stopCtx.Done()
+		unsubscribe()
+		debounces.Wait()
+	}()
+
+	return nil
+}
+
+func (trigger *EventTrigger) onEvent(event string, obj ogcore.State) bool {
+	trigger.Lock()
+	stopCtx, debounces := trigger.stopCtx, trigger.debounces
+	trigger.Unlock()
+
+	if stopCtx == nil || stopCtx.Err() != nil {
+		return true
+	}
+
+	var state ogcore.State
+
+	if trigger.StateFrom != nil {
+		if state = trigger.StateFrom(event, obj); state == nil {
+			return true
+		}
+	}
+
+	run := pendingRun{ScheduledAt: trigger.Clock.Now(), Event: event, State: state}
+
+	if trigger.Debounce <= 0 {
+		trigger.fire(run)
+		return true
+	}
+
+	trigger.Lock()
+	trigger.debounceSeq++
+	seq := trigger.debounceSeq
+	trigger.Unlock()
+
+	debounces.Add(1)
+
+	go func() {
+		defer debounces.Done()
+
+		select {
+		case <-stopCtx.Done():
+			return
+		case <-trigger.Clock.After(trigger.Debounce):
+		}
+
+		trigger.Lock()
+		latest := seq == trigger.debounceSeq
+		trigger.Unlock()
+
+		if latest {
+			run.ScheduledAt = trigger.Clock.Now()
+			trigger.fire(run)
+		}
+	}()
+
+	return true
+}
+
+func NewEventTrigger(pipeline *Pipeline, source EventSource, pattern string) *EventTrigger {
+	return &EventTrigger{
+		Pipeline: pipeline,
+		Source:   source,
+		Pattern:  pattern,
+	}
+}